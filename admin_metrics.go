@@ -6,6 +6,10 @@ import (
 )
 
 func (cfg *apiConfig) handlerMetrics(writer http.ResponseWriter, request *http.Request) {
+	if !cfg.requireAdmin(writer, request) {
+		return
+	}
+
 	writer.Header().Set("Content-Type", "text/html; charset=utf-8")
 	writer.WriteHeader(http.StatusOK)
 	writer.Write([]byte(fmt.Sprintf(`
@@ -6,6 +6,8 @@ import (
 	"net/http"
 )
 
+// handlerReset wipes the database. It only works on the dev platform,
+// where it's what the request samples start from, so it needs no token.
 func (cfg *apiConfig) handlerReset(writer http.ResponseWriter, request *http.Request) {
 	fmt.Println("DEBUG: Resetting...")
 
@@ -22,7 +24,7 @@ func (cfg *apiConfig) handlerReset(writer http.ResponseWriter, request *http.Req
 
 	cfg.fileserverHits.Store(0)
 
-	err := cfg.dbQueries.ResetUsers(context.Background())
+	err := cfg.dbQueries.ResetUsers(context.Background(), deletedUserID)
 	if err != nil {
 		respondWithError(writer, http.StatusInternalServerError, "Couldn't reset users", err)
 		return
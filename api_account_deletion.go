@@ -0,0 +1,184 @@
+package main
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"github.com/tomanta/chirpy/internal/auth"
+	"github.com/tomanta/chirpy/internal/database"
+	"log"
+	"net/http"
+	"time"
+)
+
+const (
+	defaultAccountDeletionGrace = 30 * 24 * time.Hour
+	accountPurgeInterval        = time.Hour
+)
+
+// deletedUserID owns purged users' chirps that are still part of someone
+// else's thread or quote. Its row is created by a migration.
+var deletedUserID = uuid.MustParse("00000000-0000-0000-0000-00000000de1e")
+
+// handlerDeleteUser soft-deletes the caller's account. It signs them out
+// everywhere and hides their chirps straight away; the purger removes the
+// account for good once the grace period is over, unless they log back in
+// first.
+func (cfg *apiConfig) handlerDeleteUser(writer http.ResponseWriter, request *http.Request) {
+	userID, ok := cfg.requireUser(writer, request, auth.ScopeAccount)
+	if !ok {
+		return
+	}
+
+	tx, err := cfg.db.BeginTx(context.Background(), nil)
+	if err != nil {
+		respondWithError(writer, http.StatusInternalServerError, "Couldn't delete user", err)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.dbQueries.WithTx(tx)
+
+	deleted, err := qtx.SoftDeleteUser(context.Background(), userID)
+	if err != nil {
+		respondWithError(writer, http.StatusInternalServerError, "Couldn't delete user", err)
+		return
+	}
+	if deleted == 0 {
+		respondWithError(writer, http.StatusNotFound, "Couldn't find user", nil)
+		return
+	}
+
+	err = revokeUserSessions(context.Background(), qtx, userID)
+	if err != nil {
+		respondWithError(writer, http.StatusInternalServerError, "Couldn't delete user", err)
+		return
+	}
+
+	err = qtx.HideUserChirps(context.Background(), userID)
+	if err != nil {
+		respondWithError(writer, http.StatusInternalServerError, "Couldn't delete user", err)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(writer, http.StatusInternalServerError, "Couldn't delete user", err)
+		return
+	}
+
+	writer.WriteHeader(http.StatusNoContent)
+}
+
+// restoreAccount cancels a pending deletion.
+func (cfg *apiConfig) restoreAccount(ctx context.Context, userID uuid.UUID) error {
+	tx, err := cfg.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	qtx := cfg.dbQueries.WithTx(tx)
+
+	err = qtx.RestoreUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	err = qtx.UnhideUserChirps(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// purgeDeletedAccounts hard-deletes accounts whose grace period has run out,
+// once immediately and then every interval until ctx is done.
+func (cfg *apiConfig) purgeDeletedAccounts(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		purged, err := cfg.purgeExpiredAccounts(ctx)
+		if err != nil {
+			log.Printf("Couldn't purge deleted accounts: %s", err)
+		} else if purged > 0 {
+			log.Printf("Purged %d deleted accounts", purged)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// purgeExpiredAccounts hard-deletes every account past its grace period,
+// returning how many went. An account that can't be purged is logged and
+// left for the next run, so it doesn't hold up the rest.
+func (cfg *apiConfig) purgeExpiredAccounts(ctx context.Context) (int, error) {
+	deletedBefore := time.Now().UTC().Add(-cfg.accountDeletionGrace)
+	userIDs, err := cfg.dbQueries.ListPurgeableUsers(ctx, deletedBefore)
+	if err != nil {
+		return 0, err
+	}
+
+	purged := 0
+	for _, userID := range userIDs {
+		ok, err := cfg.purgeAccount(ctx, userID, deletedBefore)
+		if err != nil {
+			log.Printf("Couldn't purge deleted account %s: %s", userID, err)
+			continue
+		}
+		if ok {
+			purged++
+		}
+	}
+	return purged, nil
+}
+
+// purgeAccount deletes an account for good, unless it was restored in the
+// meantime. Chirps that other users replied to or quoted are tombstoned and
+// handed to the deleted-user placeholder first, so the cascade doesn't
+// break their threads and quotes.
+func (cfg *apiConfig) purgeAccount(ctx context.Context, userID uuid.UUID, deletedBefore time.Time) (bool, error) {
+	tx, err := cfg.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+	qtx := cfg.dbQueries.WithTx(tx)
+
+	// Locking the row makes a concurrent login wait rather than restore an
+	// account halfway through being purged.
+	user, err := qtx.GetUserByIDForUpdate(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+	if !user.DeletedAt.Valid || !user.DeletedAt.Time.Before(deletedBefore) {
+		return false, nil
+	}
+
+	chirpIDs, err := qtx.ListUserChirpsWithResponses(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+	for _, chirpID := range chirpIDs {
+		err = tombstoneChirp(ctx, qtx, chirpID)
+		if err != nil {
+			return false, err
+		}
+		err = qtx.ReassignChirp(ctx, database.ReassignChirpParams{
+			UserID: deletedUserID,
+			ID:     chirpID,
+		})
+		if err != nil {
+			return false, err
+		}
+	}
+
+	_, err = qtx.PurgeUser(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
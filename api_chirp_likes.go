@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"github.com/tomanta/chirpy/internal/auth"
+	"github.com/tomanta/chirpy/internal/database"
+	"net/http"
+)
+
+func (cfg *apiConfig) handlerLikeChirp(writer http.ResponseWriter, request *http.Request) {
+	cfg.setChirpLike(writer, request, true)
+}
+
+func (cfg *apiConfig) handlerUnlikeChirp(writer http.ResponseWriter, request *http.Request) {
+	cfg.setChirpLike(writer, request, false)
+}
+
+// setChirpLike adds or removes the caller's like. Both directions are
+// idempotent and respond with the chirp's updated like fields.
+func (cfg *apiConfig) setChirpLike(writer http.ResponseWriter, request *http.Request, liked bool) {
+	userID, ok := cfg.requireUser(writer, request, auth.ScopeChirpsWrite)
+	if !ok {
+		return
+	}
+
+	chirpID, err := uuid.Parse(request.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(writer, http.StatusBadRequest, "Invalid chirp ID", err)
+		return
+	}
+
+	dbChirp, err := cfg.dbQueries.GetChirpByID(context.Background(), chirpID)
+	if err != nil || isRemoved(dbChirp) {
+		respondWithError(writer, http.StatusNotFound, "Could not retrieve chirp", err)
+		return
+	}
+
+	if liked {
+		err = cfg.dbQueries.LikeChirp(context.Background(), database.LikeChirpParams{
+			UserID:  userID,
+			ChirpID: chirpID,
+		})
+	} else {
+		err = cfg.dbQueries.UnlikeChirp(context.Background(), database.UnlikeChirpParams{
+			UserID:  userID,
+			ChirpID: chirpID,
+		})
+	}
+	if err != nil {
+		respondWithError(writer, http.StatusInternalServerError, "Could not update like", err)
+		return
+	}
+
+	chirps, err := cfg.chirpsFromDB(context.Background(), []database.Chirp{dbChirp}, uuid.NullUUID{UUID: userID, Valid: true})
+	if err != nil {
+		respondWithError(writer, http.StatusInternalServerError, "Could not retrieve chirp", err)
+		return
+	}
+
+	respondWithJSON(writer, http.StatusOK, chirps[0])
+}
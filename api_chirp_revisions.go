@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/google/uuid"
+	"github.com/tomanta/chirpy/internal/auth"
+	"github.com/tomanta/chirpy/internal/database"
+	"net/http"
+	"time"
+)
+
+type ChirpRevision struct {
+	ID         uuid.UUID `json:"id"`
+	Body       string    `json:"body"`
+	CreatedAt  time.Time `json:"created_at"`
+	ReplacedAt time.Time `json:"replaced_at"`
+}
+
+func (cfg *apiConfig) handlerUpdateChirp(writer http.ResponseWriter, request *http.Request) {
+	type parameters struct {
+		Body string `json:"body"`
+	}
+
+	userID, ok := cfg.requireUser(writer, request, auth.ScopeChirpsWrite)
+	if !ok {
+		return
+	}
+
+	chirpID, err := uuid.Parse(request.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(writer, http.StatusBadRequest, "Invalid chirp ID", err)
+		return
+	}
+
+	decoder := json.NewDecoder(request.Body)
+	params := parameters{}
+	err = decoder.Decode(&params)
+	if err != nil {
+		respondWithError(writer, http.StatusInternalServerError, "Could not decode parameters", err)
+		return
+	}
+
+	body, err := validateChirpBody(params.Body)
+	if err != nil {
+		respondWithError(writer, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+
+	tx, err := cfg.db.BeginTx(context.Background(), nil)
+	if err != nil {
+		respondWithError(writer, http.StatusInternalServerError, "Could not update chirp", err)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.dbQueries.WithTx(tx)
+
+	// Lock the row so concurrent edits each record the body they replaced.
+	dbChirp, err := qtx.GetChirpByIDForUpdate(context.Background(), chirpID)
+	if err != nil || isRemoved(dbChirp) {
+		respondWithError(writer, http.StatusNotFound, "Could not retrieve chirp", err)
+		return
+	}
+
+	if dbChirp.UserID != userID {
+		respondWithError(writer, http.StatusForbidden, "", nil)
+		return
+	}
+
+	if dbChirp.RechirpOf.Valid {
+		respondWithError(writer, http.StatusBadRequest, "Rechirps cannot be edited", nil)
+		return
+	}
+
+	if body != dbChirp.Body {
+		writtenAt := dbChirp.CreatedAt
+		if dbChirp.EditedAt.Valid {
+			writtenAt = dbChirp.EditedAt.Time
+		}
+
+		_, err = qtx.CreateChirpRevision(context.Background(), database.CreateChirpRevisionParams{
+			ChirpID:   dbChirp.ID,
+			Body:      dbChirp.Body,
+			CreatedAt: writtenAt,
+		})
+		if err != nil {
+			respondWithError(writer, http.StatusInternalServerError, "Could not update chirp", err)
+			return
+		}
+
+		dbChirp, err = qtx.UpdateChirpBody(context.Background(), database.UpdateChirpBodyParams{
+			Body: body,
+			ID:   dbChirp.ID,
+		})
+		if err != nil {
+			respondWithError(writer, http.StatusInternalServerError, "Could not update chirp", err)
+			return
+		}
+
+		err = qtx.DeleteChirpHashtags(context.Background(), dbChirp.ID)
+		if err != nil {
+			respondWithError(writer, http.StatusInternalServerError, "Could not update chirp", err)
+			return
+		}
+
+		err = indexHashtags(context.Background(), qtx, dbChirp)
+		if err != nil {
+			respondWithError(writer, http.StatusInternalServerError, "Could not update chirp", err)
+			return
+		}
+
+		err = syncMentions(context.Background(), qtx, dbChirp)
+		if err != nil {
+			respondWithError(writer, http.StatusInternalServerError, "Could not update chirp", err)
+			return
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(writer, http.StatusInternalServerError, "Could not update chirp", err)
+		return
+	}
+
+	chirps, err := cfg.chirpsFromDB(context.Background(), []database.Chirp{dbChirp}, uuid.NullUUID{UUID: userID, Valid: true})
+	if err != nil {
+		respondWithError(writer, http.StatusInternalServerError, "Could not retrieve chirp", err)
+		return
+	}
+
+	respondWithJSON(writer, http.StatusOK, chirps[0])
+}
+
+func (cfg *apiConfig) handlerGetChirpRevisions(writer http.ResponseWriter, request *http.Request) {
+	chirpID, err := uuid.Parse(request.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(writer, http.StatusBadRequest, "Invalid chirp ID", err)
+		return
+	}
+
+	dbChirp, err := cfg.dbQueries.GetChirpByID(context.Background(), chirpID)
+	if err != nil || isRemoved(dbChirp) {
+		respondWithError(writer, http.StatusNotFound, "Could not retrieve chirp", err)
+		return
+	}
+
+	dbRevisions, err := cfg.dbQueries.GetChirpRevisions(context.Background(), chirpID)
+	if err != nil {
+		respondWithError(writer, http.StatusInternalServerError, "Could not retrieve revisions", err)
+		return
+	}
+
+	revisions := []ChirpRevision{}
+	for _, r := range dbRevisions {
+		revisions = append(revisions, ChirpRevision{
+			ID:         r.ID,
+			Body:       r.Body,
+			CreatedAt:  r.CreatedAt,
+			ReplacedAt: r.ReplacedAt,
+		})
+	}
+
+	respondWithJSON(writer, http.StatusOK, revisions)
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"github.com/tomanta/chirpy/internal/database"
+	"net/http"
+	"strings"
+)
+
+const maxSearchQueryLength = 256
+
+func (cfg *apiConfig) handlerSearchChirps(writer http.ResponseWriter, request *http.Request) {
+	query := strings.TrimSpace(request.URL.Query().Get("q"))
+	if query == "" {
+		respondWithError(writer, http.StatusBadRequest, "Request does not contain q parameter", nil)
+		return
+	}
+	if len(query) > maxSearchQueryLength {
+		respondWithError(writer, http.StatusBadRequest, "Search query is too long", nil)
+		return
+	}
+
+	filter := chirpFilter{}
+	if author := request.URL.Query().Get("author_id"); author != "" {
+		authorID, err := uuid.Parse(author)
+		if err != nil {
+			respondWithError(writer, http.StatusBadRequest, "Invalid author id", err)
+			return
+		}
+		filter.AuthorID = uuid.NullUUID{UUID: authorID, Valid: true}
+	}
+
+	page, err := parsePageRequest(request.URL.Query(), false)
+	if err != nil {
+		respondWithError(writer, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+	// Results are always ranked best match first.
+	page.Ascending = false
+
+	hits, err := cfg.searchChirps(context.Background(), query, filter, page)
+	if err != nil {
+		respondWithError(writer, http.StatusInternalServerError, "Could not search chirps", err)
+		return
+	}
+
+	hits, nextCursor, prevCursor := pageCursors(page, hits, func(h searchHit) pageCursor {
+		return pageCursor{Rank: h.Rank, CreatedAt: h.CreatedAt, ID: h.ID}
+	})
+
+	dbChirps, err := cfg.chirpsInOrder(context.Background(), hits)
+	if err != nil {
+		respondWithError(writer, http.StatusInternalServerError, "Could not search chirps", err)
+		return
+	}
+
+	cfg.respondWithChirps(writer, request, dbChirps, nextCursor, prevCursor)
+}
+
+// chirpsInOrder loads the chirps behind a page of search hits, keeping the
+// ranking order. Hits deleted in the meantime are skipped.
+func (cfg *apiConfig) chirpsInOrder(ctx context.Context, hits []searchHit) ([]database.Chirp, error) {
+	if len(hits) == 0 {
+		return []database.Chirp{}, nil
+	}
+
+	ids := make([]uuid.UUID, 0, len(hits))
+	for _, h := range hits {
+		ids = append(ids, h.ID)
+	}
+
+	dbChirps, err := cfg.dbQueries.GetChirpsByIDs(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	byID := map[uuid.UUID]database.Chirp{}
+	for _, c := range dbChirps {
+		byID[c.ID] = c
+	}
+
+	ordered := []database.Chirp{}
+	for _, h := range hits {
+		if c, ok := byID[h.ID]; ok && !isRemoved(c) {
+			ordered = append(ordered, c)
+		}
+	}
+	return ordered, nil
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"github.com/tomanta/chirpy/internal/database"
+	"net/http"
+)
+
+const (
+	maxThreadDepth   = 200
+	maxThreadReplies = 500
+)
+
+type ThreadNode struct {
+	Chirp
+	Replies []ThreadNode `json:"replies"`
+}
+
+type Thread struct {
+	Ancestors []Chirp    `json:"ancestors"`
+	Chirp     ThreadNode `json:"chirp"`
+	Truncated bool       `json:"truncated,omitempty"`
+}
+
+func (cfg *apiConfig) handlerGetChirpReplies(writer http.ResponseWriter, request *http.Request) {
+	chirpID, err := uuid.Parse(request.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(writer, http.StatusBadRequest, "Invalid chirp ID", err)
+		return
+	}
+
+	page, err := parsePageRequest(request.URL.Query(), true)
+	if err != nil {
+		respondWithError(writer, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+
+	_, err = cfg.dbQueries.GetChirpByID(context.Background(), chirpID)
+	if err != nil {
+		respondWithError(writer, http.StatusNotFound, "Could not retrieve chirp", err)
+		return
+	}
+
+	dbChirps, err := cfg.listReplies(context.Background(), chirpID, page)
+	if err != nil {
+		respondWithError(writer, http.StatusInternalServerError, "Could not retrieve replies", err)
+		return
+	}
+
+	cfg.respondWithChirpPage(writer, request, page, dbChirps)
+}
+
+func (cfg *apiConfig) handlerGetChirpThread(writer http.ResponseWriter, request *http.Request) {
+	chirpID, err := uuid.Parse(request.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(writer, http.StatusBadRequest, "Invalid chirp ID", err)
+		return
+	}
+
+	dbChirp, err := cfg.dbQueries.GetChirpByID(context.Background(), chirpID)
+	if err != nil {
+		respondWithError(writer, http.StatusNotFound, "Could not retrieve chirp", err)
+		return
+	}
+
+	dbAncestors, err := cfg.dbQueries.GetChirpAncestors(context.Background(), database.GetChirpAncestorsParams{
+		ChirpID:  chirpID,
+		MaxDepth: maxThreadDepth,
+	})
+	if err != nil {
+		respondWithError(writer, http.StatusInternalServerError, "Could not retrieve thread", err)
+		return
+	}
+
+	dbDescendants, err := cfg.dbQueries.GetChirpDescendants(context.Background(), database.GetChirpDescendantsParams{
+		ChirpID:  chirpID,
+		RowLimit: maxThreadReplies + 1,
+	})
+	if err != nil {
+		respondWithError(writer, http.StatusInternalServerError, "Could not retrieve thread", err)
+		return
+	}
+
+	thread := Thread{
+		Ancestors: []Chirp{},
+		Truncated: len(dbDescendants) > maxThreadReplies,
+	}
+	if thread.Truncated {
+		dbDescendants = dbDescendants[:maxThreadReplies]
+	}
+
+	dbThread := append(append(dbAncestors, dbChirp), dbDescendants...)
+	chirps, err := cfg.chirpsFromDB(context.Background(), dbThread, cfg.viewerID(request))
+	if err != nil {
+		respondWithError(writer, http.StatusInternalServerError, "Could not retrieve thread", err)
+		return
+	}
+
+	thread.Ancestors = append(thread.Ancestors, chirps[:len(dbAncestors)]...)
+	thread.Chirp = buildReplyTree(chirps[len(dbAncestors)], chirps[len(dbAncestors)+1:])
+
+	respondWithJSON(writer, http.StatusOK, thread)
+}
+
+// buildReplyTree nests descendants, which arrive in creation order, under
+// root. Replies whose parent was cut off by the row limit are dropped.
+func buildReplyTree(root Chirp, descendants []Chirp) ThreadNode {
+	children := map[uuid.UUID][]Chirp{}
+	for _, c := range descendants {
+		children[*c.InReplyTo] = append(children[*c.InReplyTo], c)
+	}
+
+	var build func(c Chirp) ThreadNode
+	build = func(c Chirp) ThreadNode {
+		node := ThreadNode{
+			Chirp:   c,
+			Replies: []ThreadNode{},
+		}
+		for _, child := range children[c.ID] {
+			node.Replies = append(node.Replies, build(child))
+		}
+		return node
+	}
+	return build(root)
+}
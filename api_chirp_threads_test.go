@@ -0,0 +1,94 @@
+package main
+
+import (
+	"github.com/google/uuid"
+	"strings"
+	"testing"
+)
+
+// threadShape writes a reply tree as nested names, such as "root(a(b) c)",
+// with deleted chirps marked by a trailing "~".
+func threadShape(node ThreadNode, names map[uuid.UUID]string) string {
+	shape := names[node.ID]
+	if node.Deleted {
+		shape += "~"
+	}
+	if len(node.Replies) == 0 {
+		return shape
+	}
+	replies := []string{}
+	for _, reply := range node.Replies {
+		replies = append(replies, threadShape(reply, names))
+	}
+	return shape + "(" + strings.Join(replies, " ") + ")"
+}
+
+func TestBuildReplyTree(t *testing.T) {
+	ids := map[string]uuid.UUID{}
+	names := map[uuid.UUID]string{}
+	for i, name := range []string{"root", "a", "b", "c", "gone", "d", "missing", "orphan"} {
+		ids[name] = uuid.UUID{15: byte(i + 1)}
+		names[ids[name]] = name
+	}
+	chirp := func(name, parent string) Chirp {
+		c := Chirp{ID: ids[name]}
+		if parent != "" {
+			parentID := ids[parent]
+			c.InReplyTo = &parentID
+		}
+		return c
+	}
+	tombstone := func(c Chirp) Chirp {
+		c.Deleted = true
+		return c
+	}
+
+	tests := []struct {
+		name        string
+		descendants []Chirp
+		want        string
+	}{
+		{
+			name:        "No replies",
+			descendants: []Chirp{},
+			want:        "root",
+		},
+		{
+			name: "Nested replies keep creation order",
+			descendants: []Chirp{
+				chirp("a", "root"),
+				chirp("b", "a"),
+				chirp("c", "root"),
+				chirp("d", "b"),
+			},
+			want: "root(a(b(d)) c)",
+		},
+		{
+			name: "Replies to a tombstoned chirp",
+			descendants: []Chirp{
+				tombstone(chirp("gone", "root")),
+				chirp("a", "gone"),
+				chirp("b", "a"),
+			},
+			want: "root(gone~(a(b)))",
+		},
+		{
+			name: "Parent missing",
+			descendants: []Chirp{
+				chirp("a", "root"),
+				chirp("orphan", "missing"),
+				chirp("d", "orphan"),
+			},
+			want: "root(a)",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tree := buildReplyTree(chirp("root", ""), tt.descendants)
+			if got := threadShape(tree, names); got != tt.want {
+				t.Errorf("buildReplyTree() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
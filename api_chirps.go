@@ -2,72 +2,212 @@ package main
 
 import (
 	"context"
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"github.com/google/uuid"
 	"github.com/tomanta/chirpy/internal/auth"
 	"github.com/tomanta/chirpy/internal/database"
 	"net/http"
 	"strings"
 	"time"
-	"sort"
 )
 
+const maxChirpLength = 140
+
 type Chirp struct {
-	ID        uuid.UUID `json:"id"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
-	Body      string    `json:"body"`
-	UserID    uuid.UUID `json:"user_id"`
+	ID        uuid.UUID  `json:"id"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+	Body      string     `json:"body"`
+	UserID    uuid.UUID  `json:"user_id"`
+	InReplyTo *uuid.UUID `json:"in_reply_to,omitempty"`
+	Deleted   bool       `json:"deleted,omitempty"`
+	RechirpOf *uuid.UUID `json:"rechirp_of,omitempty"`
+	QuoteOf   *uuid.UUID `json:"quote_of,omitempty"`
+	Original  *Chirp     `json:"original,omitempty"`
+	Edited    bool       `json:"edited"`
+	EditedAt  *time.Time `json:"edited_at,omitempty"`
+	LikeCount int64      `json:"like_count"`
+	LikedByMe bool       `json:"liked_by_me"`
 }
 
-func (cfg *apiConfig) handlerGetChirps(writer http.ResponseWriter, request *http.Request) {
+func chirpFromDB(c database.Chirp) Chirp {
+	chirp := Chirp{
+		ID:        c.ID,
+		CreatedAt: c.CreatedAt,
+		UpdatedAt: c.UpdatedAt,
+		Body:      c.Body,
+		UserID:    c.UserID,
+		Deleted:   isRemoved(c),
+	}
+	// Chirps of an account pending deletion read as tombstones until the
+	// account is restored or purged.
+	if c.HiddenAt.Valid {
+		chirp.Body = ""
+	}
+	if c.InReplyTo.Valid {
+		chirp.InReplyTo = &c.InReplyTo.UUID
+	}
+	if c.RechirpOf.Valid {
+		chirp.RechirpOf = &c.RechirpOf.UUID
+	}
+	if c.QuoteOf.Valid {
+		chirp.QuoteOf = &c.QuoteOf.UUID
+	}
+	if c.EditedAt.Valid {
+		chirp.Edited = true
+		chirp.EditedAt = &c.EditedAt.Time
+	}
+	return chirp
+}
 
-	author := request.URL.Query().Get("author_id")
-	authorID := uuid.Nil
-	if author != "" {
+// isRemoved reports whether a chirp was deleted, or hidden along with its
+// author's account.
+func isRemoved(c database.Chirp) bool {
+	return c.DeletedAt.Valid || c.HiddenAt.Valid
+}
+
+// chirpsFromDB converts a batch of chirps for viewer. Rechirped and quoted
+// originals are embedded, and like fields are filled in for the batch and its
+// originals, using a fixed number of queries however large the batch is.
+func (cfg *apiConfig) chirpsFromDB(ctx context.Context, dbChirps []database.Chirp, viewer uuid.NullUUID) ([]Chirp, error) {
+	chirps := []Chirp{}
+	if len(dbChirps) == 0 {
+		return chirps, nil
+	}
+
+	originalIDs := []uuid.UUID{}
+	for _, c := range dbChirps {
+		if c.RechirpOf.Valid {
+			originalIDs = append(originalIDs, c.RechirpOf.UUID)
+		}
+		if c.QuoteOf.Valid {
+			originalIDs = append(originalIDs, c.QuoteOf.UUID)
+		}
+	}
+	dbOriginals := []database.Chirp{}
+	if len(originalIDs) > 0 {
 		var err error
-		authorID, err = uuid.Parse(author)
+		dbOriginals, err = cfg.dbQueries.GetChirpsByIDs(ctx, originalIDs)
 		if err != nil {
-			respondWithError(writer, http.StatusBadRequest, "Invalid author id", err)
-			return
+			return nil, err
 		}
 	}
 
-	sortOrder := request.URL.Query().Get("sort")
-	if sortOrder != "" {
-		if sortOrder != "asc" && sortOrder != "desc" {
-			respondWithError(writer, http.StatusBadRequest, "Invalid sort order", nil)
-			return
-		}
+	ids := make([]uuid.UUID, 0, len(dbChirps)+len(dbOriginals))
+	for _, c := range dbChirps {
+		ids = append(ids, c.ID)
+	}
+	for _, c := range dbOriginals {
+		ids = append(ids, c.ID)
 	}
 
-	dbChirps, err := cfg.dbQueries.GetChirps(context.Background())
+	likeStats, err := cfg.dbQueries.GetChirpLikeStats(ctx, database.GetChirpLikeStatsParams{
+		ViewerID: viewer,
+		ChirpIds: ids,
+	})
 	if err != nil {
-		respondWithError(writer, http.StatusBadRequest, "Could not retrieve chirps", err)
-		return
+		return nil, err
+	}
+	likes := map[uuid.UUID]database.GetChirpLikeStatsRow{}
+	for _, l := range likeStats {
+		likes[l.ChirpID] = l
+	}
+
+	withLikes := func(c database.Chirp) Chirp {
+		chirp := chirpFromDB(c)
+		chirp.LikeCount = likes[c.ID].LikeCount
+		chirp.LikedByMe = likes[c.ID].LikedByViewer
+		return chirp
+	}
+
+	originals := map[uuid.UUID]Chirp{}
+	for _, c := range dbOriginals {
+		originals[c.ID] = withLikes(c)
 	}
 
-	chirps := []Chirp{}
 	for _, c := range dbChirps {
-		if author == "" || authorID == c.UserID {
-			chirps = append(chirps, Chirp{
-				ID:        c.ID,
-				CreatedAt: c.CreatedAt,
-				UpdatedAt: c.UpdatedAt,
-				Body:      c.Body,
-				UserID:    c.UserID,
-			})
+		chirp := withLikes(c)
+		if original, ok := originals[c.RechirpOf.UUID]; ok && c.RechirpOf.Valid {
+			chirp.Original = &original
 		}
+		if original, ok := originals[c.QuoteOf.UUID]; ok && c.QuoteOf.Valid {
+			chirp.Original = &original
+		}
+		chirps = append(chirps, chirp)
 	}
+	return chirps, nil
+}
 
-	if sortOrder == "desc" {
-		sort.Slice(chirps, func(i, j int) bool {
-			return chirps[i].CreatedAt.After(chirps[j].CreatedAt)
-		})
+// viewerID identifies the caller on public endpoints so per-viewer fields can
+// be filled in. A missing or invalid token just means an anonymous viewer.
+func (cfg *apiConfig) viewerID(request *http.Request) uuid.NullUUID {
+	token, err := auth.GetBearerToken(request.Header)
+	if err != nil {
+		return uuid.NullUUID{}
+	}
+	claims, err := cfg.validateAccessToken(request.Context(), token)
+	if err != nil {
+		return uuid.NullUUID{}
 	}
+	return uuid.NullUUID{UUID: claims.UserID, Valid: true}
+}
 
-	respondWithJSON(writer, http.StatusOK, chirps)
+type ChirpPage struct {
+	Chirps     []Chirp `json:"chirps"`
+	NextCursor string  `json:"next_cursor,omitempty"`
+	PrevCursor string  `json:"prev_cursor,omitempty"`
+}
 
+func (cfg *apiConfig) handlerGetChirps(writer http.ResponseWriter, request *http.Request) {
+
+	filter := chirpFilter{}
+	if author := request.URL.Query().Get("author_id"); author != "" {
+		authorID, err := uuid.Parse(author)
+		if err != nil {
+			respondWithError(writer, http.StatusBadRequest, "Invalid author id", err)
+			return
+		}
+		filter.AuthorID = uuid.NullUUID{UUID: authorID, Valid: true}
+	}
+
+	page, err := parsePageRequest(request.URL.Query(), true)
+	if err != nil {
+		respondWithError(writer, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+
+	dbChirps, err := cfg.listChirps(context.Background(), filter, page)
+	if err != nil {
+		respondWithError(writer, http.StatusInternalServerError, "Could not retrieve chirps", err)
+		return
+	}
+
+	cfg.respondWithChirpPage(writer, request, page, dbChirps)
+}
+
+// respondWithChirpPage writes one page of a keyset listing fetched with a
+// look-ahead row, along with the cursors for its neighbours.
+func (cfg *apiConfig) respondWithChirpPage(writer http.ResponseWriter, request *http.Request, page pageRequest, dbChirps []database.Chirp) {
+	dbChirps, nextCursor, prevCursor := pageCursors(page, dbChirps, func(c database.Chirp) pageCursor {
+		return pageCursor{CreatedAt: c.CreatedAt, ID: c.ID}
+	})
+	cfg.respondWithChirps(writer, request, dbChirps, nextCursor, prevCursor)
+}
+
+func (cfg *apiConfig) respondWithChirps(writer http.ResponseWriter, request *http.Request, dbChirps []database.Chirp, nextCursor, prevCursor string) {
+	chirps, err := cfg.chirpsFromDB(context.Background(), dbChirps, cfg.viewerID(request))
+	if err != nil {
+		respondWithError(writer, http.StatusInternalServerError, "Could not retrieve chirps", err)
+		return
+	}
+
+	respondWithJSON(writer, http.StatusOK, ChirpPage{
+		Chirps:     chirps,
+		NextCursor: nextCursor,
+		PrevCursor: prevCursor,
+	})
 }
 
 func (cfg *apiConfig) handlerGetChirpByID(writer http.ResponseWriter, request *http.Request) {
@@ -79,82 +219,202 @@ func (cfg *apiConfig) handlerGetChirpByID(writer http.ResponseWriter, request *h
 	}
 
 	dbResponse, err := cfg.dbQueries.GetChirpByID(context.Background(), chirpID)
-	if err != nil {
+	if err != nil || isRemoved(dbResponse) {
 		respondWithError(writer, http.StatusNotFound, "Could not retrieve chirp", err)
 		return
 	}
 
-	respondWithJSON(writer, http.StatusOK, Chirp{
-		ID:        dbResponse.ID,
-		CreatedAt: dbResponse.CreatedAt,
-		UpdatedAt: dbResponse.UpdatedAt,
-		Body:      dbResponse.Body,
-		UserID:    dbResponse.UserID,
-	})
+	chirps, err := cfg.chirpsFromDB(context.Background(), []database.Chirp{dbResponse}, cfg.viewerID(request))
+	if err != nil {
+		respondWithError(writer, http.StatusInternalServerError, "Could not retrieve chirp", err)
+		return
+	}
+
+	respondWithJSON(writer, http.StatusOK, chirps[0])
 
 }
 
 func (cfg *apiConfig) handlerCreateChirp(writer http.ResponseWriter, request *http.Request) {
 	type parameters struct {
-		Body string `json:"body"`
+		Body      string     `json:"body"`
+		InReplyTo *uuid.UUID `json:"in_reply_to"`
+		RechirpOf *uuid.UUID `json:"rechirp_of"`
+		QuoteOf   *uuid.UUID `json:"quote_of"`
 	}
 
-	token, err := auth.GetBearerToken(request.Header)
-	if err != nil {
-		respondWithError(writer, http.StatusUnauthorized, "Could not find JWT", err)
+	userID, ok := cfg.requireUser(writer, request, auth.ScopeChirpsWrite)
+	if !ok {
 		return
 	}
 
-	userID, err := auth.ValidateJWT(token, cfg.jwtSecret)
-	if err != nil {
-		respondWithError(writer, http.StatusUnauthorized, "Could not validate JWT", err)
-		return
+	if cfg.requireVerifiedEmail {
+		user, err := cfg.dbQueries.GetUser(context.Background(), userID)
+		if err != nil {
+			respondWithError(writer, http.StatusUnauthorized, "Couldn't find user", err)
+			return
+		}
+		if !user.EmailVerified {
+			respondWithError(writer, http.StatusForbidden, "Email address must be verified before posting", nil)
+			return
+		}
 	}
 
 	decoder := json.NewDecoder(request.Body)
 	params := parameters{}
-	err = decoder.Decode(&params)
+	err := decoder.Decode(&params)
 	if err != nil {
 		respondWithError(writer, http.StatusInternalServerError, "Could not decode parameters", err)
 		return
 	}
 
-	// user, err := cfg.dbQueries.GetUser(context.Background(), params.UserID)
-	// if err != nil {
-	// 	respondWithError(writer, http.StatusBadRequest, "Invalid user_id", err)
-	// 	return
-	// }
-
-	if params.Body == "" {
-		respondWithError(writer, http.StatusBadRequest, "Request does not contain body parameter", err)
+	// A plain rechirp carries no body of its own.
+	if params.RechirpOf != nil {
+		if params.Body != "" || params.InReplyTo != nil || params.QuoteOf != nil {
+			respondWithError(writer, http.StatusBadRequest, "A rechirp cannot have a body, reply or quote", nil)
+			return
+		}
+		cfg.createRechirp(writer, userID, *params.RechirpOf)
 		return
 	}
 
-	const maxChirpLength = 140
-	if len(params.Body) > maxChirpLength {
-		respondWithError(writer, http.StatusBadRequest, "Chirp is too long", nil)
+	body, err := validateChirpBody(params.Body)
+	if err != nil {
+		respondWithError(writer, http.StatusBadRequest, err.Error(), nil)
 		return
 	}
 
 	newChirp := database.CreateChirpParams{
-		Body:   cleanBody(params.Body),
+		Body:   body,
 		UserID: userID,
 	}
 
-	newChirpResponse, err := cfg.dbQueries.CreateChirp(context.Background(), newChirp)
+	if params.InReplyTo != nil {
+		parent, err := cfg.referencedChirp(context.Background(), *params.InReplyTo)
+		if err != nil {
+			respondWithError(writer, http.StatusBadRequest, "Chirp being replied to does not exist", err)
+			return
+		}
+		newChirp.InReplyTo = uuid.NullUUID{UUID: parent.ID, Valid: true}
+	}
+
+	if params.QuoteOf != nil {
+		quoted, err := cfg.referencedChirp(context.Background(), *params.QuoteOf)
+		if err != nil {
+			respondWithError(writer, http.StatusBadRequest, "Chirp being quoted does not exist", err)
+			return
+		}
+		newChirp.QuoteOf = uuid.NullUUID{UUID: quoted.ID, Valid: true}
+	}
+
+	tx, err := cfg.db.BeginTx(context.Background(), nil)
+	if err != nil {
+		respondWithError(writer, http.StatusInternalServerError, "Could not create chirp", err)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.dbQueries.WithTx(tx)
+
+	newChirpResponse, err := qtx.CreateChirp(context.Background(), newChirp)
 	if err != nil {
 		respondWithError(writer, http.StatusBadRequest, "Could not create chirp", err)
 		return
 	}
 
+	err = indexHashtags(context.Background(), qtx, newChirpResponse)
+	if err != nil {
+		respondWithError(writer, http.StatusInternalServerError, "Could not create chirp", err)
+		return
+	}
+
+	err = syncMentions(context.Background(), qtx, newChirpResponse)
+	if err != nil {
+		respondWithError(writer, http.StatusInternalServerError, "Could not create chirp", err)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(writer, http.StatusInternalServerError, "Could not create chirp", err)
+		return
+	}
+
 	// Chirp is under max length
-	respondWithJSON(writer, http.StatusCreated, Chirp{
-		ID:        newChirpResponse.ID,
-		CreatedAt: newChirpResponse.CreatedAt,
-		UpdatedAt: newChirpResponse.UpdatedAt,
-		Body:      newChirpResponse.Body,
-		UserID:    newChirpResponse.UserID,
+	cfg.respondWithCreatedChirp(writer, userID, newChirpResponse)
+}
+
+func (cfg *apiConfig) createRechirp(writer http.ResponseWriter, userID, originalID uuid.UUID) {
+	original, err := cfg.referencedChirp(context.Background(), originalID)
+	if err != nil {
+		respondWithError(writer, http.StatusBadRequest, "Chirp being rechirped does not exist", err)
+		return
+	}
+
+	rechirpOf := uuid.NullUUID{UUID: original.ID, Valid: true}
+	_, err = cfg.dbQueries.GetRechirp(context.Background(), database.GetRechirpParams{
+		UserID:    userID,
+		RechirpOf: rechirpOf,
 	})
+	if err == nil {
+		respondWithError(writer, http.StatusConflict, "Chirp has already been rechirped", nil)
+		return
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		respondWithError(writer, http.StatusInternalServerError, "Could not create chirp", err)
+		return
+	}
+
+	rechirp, err := cfg.dbQueries.CreateChirp(context.Background(), database.CreateChirpParams{
+		UserID:    userID,
+		RechirpOf: rechirpOf,
+	})
+	if err != nil {
+		respondWithError(writer, http.StatusBadRequest, "Could not create chirp", err)
+		return
+	}
+
+	cfg.respondWithCreatedChirp(writer, userID, rechirp)
+}
+
+func (cfg *apiConfig) respondWithCreatedChirp(writer http.ResponseWriter, userID uuid.UUID, dbChirp database.Chirp) {
+	chirps, err := cfg.chirpsFromDB(context.Background(), []database.Chirp{dbChirp}, uuid.NullUUID{UUID: userID, Valid: true})
+	if err != nil {
+		respondWithError(writer, http.StatusInternalServerError, "Could not retrieve chirp", err)
+		return
+	}
+
+	respondWithJSON(writer, http.StatusCreated, chirps[0])
+}
+
+// referencedChirp looks up a chirp that a new chirp replies to or shares.
+// Tombstones cannot be referenced, and a plain rechirp stands in for its
+// original so replies and shares always attach to real content.
+func (cfg *apiConfig) referencedChirp(ctx context.Context, chirpID uuid.UUID) (database.Chirp, error) {
+	dbChirp, err := cfg.dbQueries.GetChirpByID(ctx, chirpID)
+	if err != nil {
+		return database.Chirp{}, err
+	}
+	if dbChirp.RechirpOf.Valid {
+		dbChirp, err = cfg.dbQueries.GetChirpByID(ctx, dbChirp.RechirpOf.UUID)
+		if err != nil {
+			return database.Chirp{}, err
+		}
+	}
+	if isRemoved(dbChirp) {
+		return database.Chirp{}, errors.New("chirp has been deleted")
+	}
+	return dbChirp, nil
+}
+
+// validateChirpBody applies the length limit and profanity filter shared by
+// new and edited chirps.
+func validateChirpBody(body string) (string, error) {
+	if body == "" {
+		return "", errors.New("Request does not contain body parameter")
+	}
+	if len(body) > maxChirpLength {
+		return "", errors.New("Chirp is too long")
+	}
+	return cleanBody(body), nil
 }
 
 func cleanBody(to_clean string) string {
@@ -176,15 +436,8 @@ func cleanBody(to_clean string) string {
 }
 
 func (cfg *apiConfig) handlerDeleteChirpByID(writer http.ResponseWriter, request *http.Request) {
-	token, err := auth.GetBearerToken(request.Header)
-	if err != nil {
-		respondWithError(writer, http.StatusUnauthorized, "Could not find JWT", err)
-		return
-	}
-
-	userID, err := auth.ValidateJWT(token, cfg.jwtSecret)
-	if err != nil {
-		respondWithError(writer, http.StatusUnauthorized, "Could not validate JWT", err)
+	userID, ok := cfg.requireUser(writer, request, auth.ScopeChirpsDelete)
+	if !ok {
 		return
 	}
 
@@ -195,7 +448,7 @@ func (cfg *apiConfig) handlerDeleteChirpByID(writer http.ResponseWriter, request
 	}
 
 	dbResponse, err := cfg.dbQueries.GetChirpByID(context.Background(), chirpID)
-	if err != nil {
+	if err != nil || isRemoved(dbResponse) {
 		respondWithError(writer, http.StatusNotFound, "Could not retrieve chirp", err)
 		return
 	}
@@ -205,7 +458,31 @@ func (cfg *apiConfig) handlerDeleteChirpByID(writer http.ResponseWriter, request
 		return
 	}
 
-	err = cfg.dbQueries.DeleteChirpByID(context.Background(), dbResponse.ID)
+	tx, err := cfg.db.BeginTx(context.Background(), nil)
+	if err != nil {
+		respondWithError(writer, http.StatusInternalServerError, "Could not delete chirp", err)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.dbQueries.WithTx(tx)
+
+	// Chirps with replies or quotes are tombstoned rather than deleted so
+	// the threads and quotes pointing at them stay intact. Everything else
+	// hanging off a hard-deleted chirp goes with it through ON DELETE CASCADE.
+	deleted, err := qtx.DeleteChirpByID(context.Background(), dbResponse.ID)
+	if err != nil {
+		respondWithError(writer, http.StatusInternalServerError, "Could not delete chirp", err)
+		return
+	}
+	if deleted == 0 {
+		err = tombstoneChirp(context.Background(), qtx, dbResponse.ID)
+		if err != nil {
+			respondWithError(writer, http.StatusInternalServerError, "Could not delete chirp", err)
+			return
+		}
+	}
+
+	err = tx.Commit()
 	if err != nil {
 		respondWithError(writer, http.StatusInternalServerError, "Could not delete chirp", err)
 		return
@@ -214,3 +491,32 @@ func (cfg *apiConfig) handlerDeleteChirpByID(writer http.ResponseWriter, request
 	writer.WriteHeader(http.StatusNoContent)
 
 }
+
+// tombstoneChirp blanks a chirp that others still point at and clears out
+// everything derived from its content.
+func tombstoneChirp(ctx context.Context, q *database.Queries, chirpID uuid.UUID) error {
+	err := q.TombstoneChirp(ctx, chirpID)
+	if err != nil {
+		return err
+	}
+
+	// Earlier bodies would otherwise outlive the deletion.
+	err = q.DeleteChirpRevisions(ctx, chirpID)
+	if err != nil {
+		return err
+	}
+
+	err = q.DeleteChirpHashtags(ctx, chirpID)
+	if err != nil {
+		return err
+	}
+
+	err = q.DeleteChirpMentions(ctx, chirpID)
+	if err != nil {
+		return err
+	}
+
+	// Plain rechirps have nothing left to show once the original is gone;
+	// quotes keep their commentary and embed the tombstone.
+	return q.DeleteRechirpsOf(ctx, uuid.NullUUID{UUID: chirpID, Valid: true})
+}
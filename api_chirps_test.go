@@ -0,0 +1,81 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"github.com/google/uuid"
+	_ "github.com/lib/pq"
+	"github.com/tomanta/chirpy/internal/database"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+// BenchmarkGetChirpsByAuthor measures GET /api/chirps?author_id=... while the
+// total number of chirps grows and the author's own chirp count stays fixed.
+// With filtering and limits running in Postgres the ns/op should stay flat
+// across the sub-benchmarks.
+//
+// The benchmark wipes the users and chirps tables, so it only runs against a
+// throwaway database named in CHIRPY_BENCH_DB_URL:
+//
+//	CHIRPY_BENCH_DB_URL=postgres://... go test -run '^$' -bench GetChirpsByAuthor
+func BenchmarkGetChirpsByAuthor(b *testing.B) {
+	dbURL := os.Getenv("CHIRPY_BENCH_DB_URL")
+	if dbURL == "" {
+		b.Skip("CHIRPY_BENCH_DB_URL not set")
+	}
+
+	db, err := sql.Open("postgres", dbURL)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer db.Close()
+
+	cfg := &apiConfig{dbQueries: database.New(db)}
+
+	const authorChirps = 100
+	for _, total := range []int{1_000, 10_000, 100_000} {
+		authorID := seedChirps(b, db, total, authorChirps)
+		url := fmt.Sprintf("/api/chirps?author_id=%s&sort=desc&limit=20", authorID)
+
+		b.Run(fmt.Sprintf("total=%d", total), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				recorder := httptest.NewRecorder()
+				cfg.handlerGetChirps(recorder, httptest.NewRequest(http.MethodGet, url, nil))
+				if recorder.Code != http.StatusOK {
+					b.Fatalf("handlerGetChirps() status = %d, body %s", recorder.Code, recorder.Body)
+				}
+			}
+		})
+	}
+}
+
+// seedChirps resets the database to total chirps, authorChirps of which belong
+// to the returned user and the rest to a second user.
+func seedChirps(b *testing.B, db *sql.DB, total, authorChirps int) uuid.UUID {
+	b.Helper()
+
+	authorID, otherID := uuid.New(), uuid.New()
+	statements := []struct {
+		query string
+		args  []any
+	}{
+		{"DELETE FROM users", nil},
+		{"INSERT INTO users (id, email, hashed_password) VALUES ($1, $2, 'unset'), ($3, $4, 'unset')",
+			[]any{authorID, authorID.String() + "@bench", otherID, otherID.String() + "@bench"}},
+		{`INSERT INTO chirps (id, created_at, updated_at, body, user_id)
+		  SELECT gen_random_uuid(), NOW() - n * INTERVAL '1 second', NOW(), 'bench chirp',
+		         CASE WHEN n % ($2::int / $3::int) = 0 THEN $1::uuid ELSE $4::uuid END
+		  FROM generate_series(1, $2::int) AS n`,
+			[]any{authorID, total, authorChirps, otherID}},
+		{"ANALYZE chirps", nil},
+	}
+	for _, stmt := range statements {
+		if _, err := db.Exec(stmt.query, stmt.args...); err != nil {
+			b.Fatalf("seeding %d chirps: %v", total, err)
+		}
+	}
+	return authorID
+}
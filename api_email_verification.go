@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/tomanta/chirpy/internal/auth"
+	"github.com/tomanta/chirpy/internal/database"
+	"github.com/tomanta/chirpy/internal/mail"
+	"net/http"
+	"time"
+)
+
+const emailVerificationTTL = 24 * time.Hour
+
+// sendVerificationEmail issues a fresh verification token for email and mails
+// it. Only the token's hash is stored.
+func (cfg *apiConfig) sendVerificationEmail(ctx context.Context, userID uuid.UUID, email string) error {
+	token, err := auth.MakeRefreshToken()
+	if err != nil {
+		return err
+	}
+
+	err = cfg.dbQueries.CreateEmailVerificationToken(ctx, database.CreateEmailVerificationTokenParams{
+		TokenHash: auth.HashToken(token),
+		UserID:    userID,
+		Email:     email,
+		ExpiresAt: time.Now().UTC().Add(emailVerificationTTL),
+	})
+	if err != nil {
+		return err
+	}
+
+	return cfg.mailer.Send(ctx, mail.Message{
+		To:      email,
+		Subject: "Verify your Chirpy email address",
+		Body: fmt.Sprintf("Use this token to verify your email address:\n\n%s\n\nIt expires in %s.\n",
+			token, emailVerificationTTL),
+	})
+}
+
+func (cfg *apiConfig) handlerVerifyEmail(writer http.ResponseWriter, request *http.Request) {
+	type parameters struct {
+		Token string `json:"token"`
+	}
+
+	decoder := json.NewDecoder(request.Body)
+	params := parameters{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		respondWithError(writer, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+
+	tx, err := cfg.db.BeginTx(context.Background(), nil)
+	if err != nil {
+		respondWithError(writer, http.StatusInternalServerError, "Couldn't verify email", err)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.dbQueries.WithTx(tx)
+
+	issued, err := qtx.ConsumeEmailVerificationToken(context.Background(), auth.HashToken(params.Token))
+	if err != nil {
+		respondWithError(writer, http.StatusBadRequest, "Invalid or expired token", err)
+		return
+	}
+
+	user, err := qtx.MarkEmailVerified(context.Background(), database.MarkEmailVerifiedParams{
+		ID:    issued.UserID,
+		Email: issued.Email,
+	})
+	if err != nil {
+		respondWithError(writer, http.StatusBadRequest, "Invalid or expired token", err)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(writer, http.StatusInternalServerError, "Couldn't verify email", err)
+		return
+	}
+
+	respondWithJSON(writer, http.StatusOK, userFromDB(user))
+}
+
+func (cfg *apiConfig) handlerResendVerification(writer http.ResponseWriter, request *http.Request) {
+	userID, ok := cfg.requireUser(writer, request, auth.ScopeProfileWrite)
+	if !ok {
+		return
+	}
+
+	user, err := cfg.dbQueries.GetUser(context.Background(), userID)
+	if err != nil {
+		respondWithError(writer, http.StatusNotFound, "Couldn't find user", err)
+		return
+	}
+
+	if user.EmailVerified {
+		respondWithError(writer, http.StatusBadRequest, "Email address is already verified", nil)
+		return
+	}
+
+	err = cfg.sendVerificationEmail(context.Background(), user.ID, user.Email)
+	if err != nil {
+		respondWithError(writer, http.StatusInternalServerError, "Couldn't send verification email", err)
+		return
+	}
+
+	writer.WriteHeader(http.StatusAccepted)
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/tomanta/chirpy/internal/auth"
+	"github.com/tomanta/chirpy/internal/database"
+	"log"
+	"net/http"
+	"time"
+)
+
+const (
+	// Accounts with at least this many chirps are exported by a background
+	// job instead of inside the request.
+	exportAsyncThreshold = 1000
+	exportArchiveTTL     = 7 * 24 * time.Hour
+	exportWorkerInterval = 5 * time.Second
+)
+
+type ExportJob struct {
+	ID          uuid.UUID  `json:"id"`
+	Status      string     `json:"status"`
+	CreatedAt   time.Time  `json:"created_at"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+	Error       string     `json:"error,omitempty"`
+	DownloadURL string     `json:"download_url,omitempty"`
+}
+
+func exportJobFromDB(j database.GetExportJobRow) ExportJob {
+	job := ExportJob{
+		ID:        j.ID,
+		Status:    j.Status,
+		CreatedAt: j.CreatedAt,
+		Error:     j.Error.String,
+	}
+	if j.CompletedAt.Valid {
+		job.CompletedAt = &j.CompletedAt.Time
+	}
+	if j.ExpiresAt.Valid {
+		job.ExpiresAt = &j.ExpiresAt.Time
+	}
+	if j.Status == "done" {
+		job.DownloadURL = fmt.Sprintf("/api/users/me/export/%s/archive", j.ID)
+	}
+	return job
+}
+
+// handlerExportUser serves the caller's data export. Small accounts get the
+// zip straight back; larger ones get a job to poll. A job still in progress
+// is reused, but a finished one isn't, so asking again gives fresh data.
+func (cfg *apiConfig) handlerExportUser(writer http.ResponseWriter, request *http.Request) {
+	userID, ok := cfg.requireUser(writer, request, auth.ScopeAccount)
+	if !ok {
+		return
+	}
+
+	chirpCount, err := cfg.dbQueries.CountUserChirps(context.Background(), userID)
+	if err != nil {
+		respondWithError(writer, http.StatusInternalServerError, "Couldn't export data", err)
+		return
+	}
+
+	if chirpCount < exportAsyncThreshold {
+		archive, err := buildExportArchive(context.Background(), cfg.dbQueries, userID)
+		if err != nil {
+			respondWithError(writer, http.StatusInternalServerError, "Couldn't export data", err)
+			return
+		}
+		respondWithArchive(writer, archive)
+		return
+	}
+
+	active, err := cfg.dbQueries.GetActiveExportJob(context.Background(), userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		var created database.CreateExportJobRow
+		created, err = cfg.dbQueries.CreateExportJob(context.Background(), userID)
+		active = database.GetActiveExportJobRow(created)
+	}
+	if err != nil {
+		respondWithError(writer, http.StatusInternalServerError, "Couldn't export data", err)
+		return
+	}
+
+	job := exportJobFromDB(database.GetExportJobRow(active))
+
+	writer.Header().Set("Location", fmt.Sprintf("/api/users/me/export/%s", job.ID))
+	respondWithJSON(writer, http.StatusAccepted, job)
+}
+
+func (cfg *apiConfig) handlerGetExportJob(writer http.ResponseWriter, request *http.Request) {
+	userID, ok := cfg.requireUser(writer, request, auth.ScopeAccount)
+	if !ok {
+		return
+	}
+
+	jobID, err := uuid.Parse(request.PathValue("jobID"))
+	if err != nil {
+		respondWithError(writer, http.StatusBadRequest, "Invalid job ID", err)
+		return
+	}
+
+	dbJob, err := cfg.dbQueries.GetExportJob(context.Background(), database.GetExportJobParams{
+		ID:     jobID,
+		UserID: userID,
+	})
+	if err != nil {
+		respondWithError(writer, http.StatusNotFound, "Couldn't find export", err)
+		return
+	}
+
+	respondWithJSON(writer, http.StatusOK, exportJobFromDB(dbJob))
+}
+
+func (cfg *apiConfig) handlerDownloadExport(writer http.ResponseWriter, request *http.Request) {
+	userID, ok := cfg.requireUser(writer, request, auth.ScopeAccount)
+	if !ok {
+		return
+	}
+
+	jobID, err := uuid.Parse(request.PathValue("jobID"))
+	if err != nil {
+		respondWithError(writer, http.StatusBadRequest, "Invalid job ID", err)
+		return
+	}
+
+	archive, err := cfg.dbQueries.GetExportArchive(context.Background(), database.GetExportArchiveParams{
+		ID:     jobID,
+		UserID: userID,
+	})
+	if err != nil {
+		respondWithError(writer, http.StatusNotFound, "Couldn't find export", err)
+		return
+	}
+
+	respondWithArchive(writer, archive)
+}
+
+func respondWithArchive(writer http.ResponseWriter, archive []byte) {
+	filename := fmt.Sprintf("chirpy-export-%s.zip", time.Now().UTC().Format("20060102"))
+	writer.Header().Set("Content-Type", "application/zip")
+	writer.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	writer.WriteHeader(http.StatusOK)
+	writer.Write(archive)
+}
+
+// processExportJobs works through pending export jobs every interval until
+// ctx is done, and clears out archives past their expiry.
+func (cfg *apiConfig) processExportJobs(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		err := cfg.dbQueries.DeleteExpiredExportJobs(ctx)
+		if err != nil {
+			log.Printf("Couldn't delete expired exports: %s", err)
+		}
+
+		for {
+			job, err := cfg.dbQueries.ClaimExportJob(ctx)
+			if err != nil {
+				if !errors.Is(err, sql.ErrNoRows) {
+					log.Printf("Couldn't claim export job: %s", err)
+				}
+				break
+			}
+			cfg.runExportJob(ctx, job.ID, job.UserID)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (cfg *apiConfig) runExportJob(ctx context.Context, jobID, userID uuid.UUID) {
+	archive, err := buildExportArchive(ctx, cfg.dbQueries, userID)
+	if err != nil {
+		log.Printf("Export %s failed: %s", jobID, err)
+		err = cfg.dbQueries.FailExportJob(ctx, database.FailExportJobParams{
+			Error: sql.NullString{String: "Couldn't build archive", Valid: true},
+			ID:    jobID,
+		})
+		if err != nil {
+			log.Printf("Couldn't record failed export %s: %s", jobID, err)
+		}
+		return
+	}
+
+	err = cfg.dbQueries.CompleteExportJob(ctx, database.CompleteExportJobParams{
+		ExpiresAt: sql.NullTime{Time: time.Now().UTC().Add(exportArchiveTTL), Valid: true},
+		Archive:   archive,
+		ID:        jobID,
+	})
+	if err != nil {
+		log.Printf("Couldn't save export %s: %s", jobID, err)
+	}
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"github.com/tomanta/chirpy/internal/auth"
+	"github.com/tomanta/chirpy/internal/database"
+	"net/http"
+	"time"
+)
+
+type FollowedUser struct {
+	ID         uuid.UUID `json:"id"`
+	FollowedAt time.Time `json:"followed_at"`
+}
+
+type FollowPage struct {
+	Users      []FollowedUser `json:"users"`
+	NextCursor string         `json:"next_cursor,omitempty"`
+	PrevCursor string         `json:"prev_cursor,omitempty"`
+}
+
+func (cfg *apiConfig) handlerFollowUser(writer http.ResponseWriter, request *http.Request) {
+	cfg.setFollow(writer, request, true)
+}
+
+func (cfg *apiConfig) handlerUnfollowUser(writer http.ResponseWriter, request *http.Request) {
+	cfg.setFollow(writer, request, false)
+}
+
+// setFollow makes the caller follow or unfollow the user in the path. Both
+// directions are idempotent.
+func (cfg *apiConfig) setFollow(writer http.ResponseWriter, request *http.Request, follow bool) {
+	userID, ok := cfg.requireUser(writer, request, auth.ScopeProfileWrite)
+	if !ok {
+		return
+	}
+
+	followeeID, err := uuid.Parse(request.PathValue("userID"))
+	if err != nil {
+		respondWithError(writer, http.StatusBadRequest, "Invalid user ID", err)
+		return
+	}
+
+	if followeeID == userID {
+		respondWithError(writer, http.StatusBadRequest, "Users cannot follow themselves", nil)
+		return
+	}
+
+	if follow {
+		_, err = cfg.dbQueries.GetUser(context.Background(), followeeID)
+		if err != nil {
+			respondWithError(writer, http.StatusNotFound, "Couldn't find user", err)
+			return
+		}
+
+		err = cfg.dbQueries.FollowUser(context.Background(), database.FollowUserParams{
+			FollowerID: userID,
+			FolloweeID: followeeID,
+		})
+	} else {
+		err = cfg.dbQueries.UnfollowUser(context.Background(), database.UnfollowUserParams{
+			FollowerID: userID,
+			FolloweeID: followeeID,
+		})
+	}
+	if err != nil {
+		respondWithError(writer, http.StatusInternalServerError, "Couldn't update follow", err)
+		return
+	}
+
+	writer.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handlerGetFollowers(writer http.ResponseWriter, request *http.Request) {
+	cfg.getFollows(writer, request, cfg.listFollowers)
+}
+
+func (cfg *apiConfig) handlerGetFollowing(writer http.ResponseWriter, request *http.Request) {
+	cfg.getFollows(writer, request, cfg.listFollowing)
+}
+
+type followLister func(ctx context.Context, userID uuid.UUID, page pageRequest) ([]FollowedUser, error)
+
+// getFollows serves one page of either side of the follow graph, newest
+// follow first by default.
+func (cfg *apiConfig) getFollows(writer http.ResponseWriter, request *http.Request, list followLister) {
+	userID, err := uuid.Parse(request.PathValue("userID"))
+	if err != nil {
+		respondWithError(writer, http.StatusBadRequest, "Invalid user ID", err)
+		return
+	}
+
+	page, err := parsePageRequest(request.URL.Query(), false)
+	if err != nil {
+		respondWithError(writer, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+
+	users, err := list(context.Background(), userID, page)
+	if err != nil {
+		respondWithError(writer, http.StatusInternalServerError, "Couldn't retrieve users", err)
+		return
+	}
+
+	users, nextCursor, prevCursor := pageCursors(page, users, func(u FollowedUser) pageCursor {
+		return pageCursor{CreatedAt: u.FollowedAt, ID: u.ID}
+	})
+
+	respondWithJSON(writer, http.StatusOK, FollowPage{
+		Users:      users,
+		NextCursor: nextCursor,
+		PrevCursor: prevCursor,
+	})
+}
+
+func (cfg *apiConfig) listFollowers(ctx context.Context, userID uuid.UUID, page pageRequest) ([]FollowedUser, error) {
+	cursorCreatedAt, cursorID := page.cursorParams()
+	rowLimit := int32(page.Limit + 1)
+
+	users := []FollowedUser{}
+	if page.scanForward() {
+		rows, err := cfg.dbQueries.ListFollowersAsc(ctx, database.ListFollowersAscParams{
+			FolloweeID:      userID,
+			CursorCreatedAt: cursorCreatedAt,
+			CursorID:        cursorID,
+			RowLimit:        rowLimit,
+		})
+		for _, r := range rows {
+			users = append(users, FollowedUser{ID: r.UserID, FollowedAt: r.CreatedAt})
+		}
+		return users, err
+	}
+
+	rows, err := cfg.dbQueries.ListFollowersDesc(ctx, database.ListFollowersDescParams{
+		FolloweeID:      userID,
+		CursorCreatedAt: cursorCreatedAt,
+		CursorID:        cursorID,
+		RowLimit:        rowLimit,
+	})
+	for _, r := range rows {
+		users = append(users, FollowedUser{ID: r.UserID, FollowedAt: r.CreatedAt})
+	}
+	return users, err
+}
+
+func (cfg *apiConfig) listFollowing(ctx context.Context, userID uuid.UUID, page pageRequest) ([]FollowedUser, error) {
+	cursorCreatedAt, cursorID := page.cursorParams()
+	rowLimit := int32(page.Limit + 1)
+
+	users := []FollowedUser{}
+	if page.scanForward() {
+		rows, err := cfg.dbQueries.ListFollowingAsc(ctx, database.ListFollowingAscParams{
+			FollowerID:      userID,
+			CursorCreatedAt: cursorCreatedAt,
+			CursorID:        cursorID,
+			RowLimit:        rowLimit,
+		})
+		for _, r := range rows {
+			users = append(users, FollowedUser{ID: r.UserID, FollowedAt: r.CreatedAt})
+		}
+		return users, err
+	}
+
+	rows, err := cfg.dbQueries.ListFollowingDesc(ctx, database.ListFollowingDescParams{
+		FollowerID:      userID,
+		CursorCreatedAt: cursorCreatedAt,
+		CursorID:        cursorID,
+		RowLimit:        rowLimit,
+	})
+	for _, r := range rows {
+		users = append(users, FollowedUser{ID: r.UserID, FollowedAt: r.CreatedAt})
+	}
+	return users, err
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"github.com/tomanta/chirpy/internal/database"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultTrendingWindow = 24 * time.Hour
+	maxTrendingWindow     = 7 * 24 * time.Hour
+	defaultTrendingLimit  = 10
+	maxTrendingLimit      = 50
+)
+
+type TrendingHashtag struct {
+	Tag        string `json:"tag"`
+	ChirpCount int64  `json:"chirp_count"`
+}
+
+func (cfg *apiConfig) handlerGetHashtagChirps(writer http.ResponseWriter, request *http.Request) {
+	tag := normalizeHashtag(request.PathValue("tag"))
+	if tag == "" {
+		respondWithError(writer, http.StatusBadRequest, "Invalid hashtag", nil)
+		return
+	}
+
+	// Tag listings read newest first unless the client asks otherwise.
+	page, err := parsePageRequest(request.URL.Query(), false)
+	if err != nil {
+		respondWithError(writer, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+
+	dbChirps, err := cfg.listHashtagChirps(context.Background(), tag, page)
+	if err != nil {
+		respondWithError(writer, http.StatusInternalServerError, "Could not retrieve chirps", err)
+		return
+	}
+
+	cfg.respondWithChirpPage(writer, request, page, dbChirps)
+}
+
+// handlerGetTrendingHashtags ranks tags by how many chirps used them within a
+// sliding window ending now, e.g. ?window=6h&limit=20.
+func (cfg *apiConfig) handlerGetTrendingHashtags(writer http.ResponseWriter, request *http.Request) {
+	window := defaultTrendingWindow
+	if w := request.URL.Query().Get("window"); w != "" {
+		var err error
+		window, err = time.ParseDuration(w)
+		if err != nil || window <= 0 || window > maxTrendingWindow {
+			respondWithError(writer, http.StatusBadRequest, "Invalid window", err)
+			return
+		}
+	}
+
+	limit := defaultTrendingLimit
+	if l := request.URL.Query().Get("limit"); l != "" {
+		n, err := strconv.Atoi(l)
+		if err != nil || n < 1 {
+			respondWithError(writer, http.StatusBadRequest, "Invalid limit", err)
+			return
+		}
+		limit = min(n, maxTrendingLimit)
+	}
+
+	rows, err := cfg.dbQueries.GetTrendingHashtags(context.Background(), database.GetTrendingHashtagsParams{
+		Since:    time.Now().UTC().Add(-window),
+		RowLimit: int32(limit),
+	})
+	if err != nil {
+		respondWithError(writer, http.StatusInternalServerError, "Could not retrieve hashtags", err)
+		return
+	}
+
+	trending := []TrendingHashtag{}
+	for _, r := range rows {
+		trending = append(trending, TrendingHashtag{
+			Tag:        r.Tag,
+			ChirpCount: r.ChirpCount,
+		})
+	}
+
+	respondWithJSON(writer, http.StatusOK, trending)
+}
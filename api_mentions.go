@@ -0,0 +1,168 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"github.com/google/uuid"
+	"github.com/tomanta/chirpy/internal/database"
+	"net/http"
+	"time"
+)
+
+type Mention struct {
+	Chirp  Chirp      `json:"chirp"`
+	Read   bool       `json:"read"`
+	ReadAt *time.Time `json:"read_at,omitempty"`
+}
+
+type MentionPage struct {
+	Mentions    []Mention `json:"mentions"`
+	UnreadCount int64     `json:"unread_count"`
+	NextCursor  string    `json:"next_cursor,omitempty"`
+	PrevCursor  string    `json:"prev_cursor,omitempty"`
+}
+
+type mentionRow struct {
+	Chirp  database.Chirp
+	ReadAt sql.NullTime
+}
+
+func (cfg *apiConfig) handlerGetMentions(writer http.ResponseWriter, request *http.Request) {
+	userID, ok := cfg.requireUser(writer, request)
+	if !ok {
+		return
+	}
+
+	unreadOnly := false
+	switch request.URL.Query().Get("unread") {
+	case "":
+	case "true":
+		unreadOnly = true
+	case "false":
+	default:
+		respondWithError(writer, http.StatusBadRequest, "Invalid unread filter", nil)
+		return
+	}
+
+	// Like the timeline, the inbox reads newest first by default.
+	page, err := parsePageRequest(request.URL.Query(), false)
+	if err != nil {
+		respondWithError(writer, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+
+	rows, err := cfg.listMentions(context.Background(), userID, unreadOnly, page)
+	if err != nil {
+		respondWithError(writer, http.StatusInternalServerError, "Could not retrieve mentions", err)
+		return
+	}
+
+	rows, nextCursor, prevCursor := pageCursors(page, rows, func(r mentionRow) pageCursor {
+		return pageCursor{CreatedAt: r.Chirp.CreatedAt, ID: r.Chirp.ID}
+	})
+
+	dbChirps := []database.Chirp{}
+	for _, r := range rows {
+		dbChirps = append(dbChirps, r.Chirp)
+	}
+
+	chirps, err := cfg.chirpsFromDB(context.Background(), dbChirps, uuid.NullUUID{UUID: userID, Valid: true})
+	if err != nil {
+		respondWithError(writer, http.StatusInternalServerError, "Could not retrieve mentions", err)
+		return
+	}
+
+	unreadCount, err := cfg.dbQueries.CountUnreadMentions(context.Background(), userID)
+	if err != nil {
+		respondWithError(writer, http.StatusInternalServerError, "Could not retrieve mentions", err)
+		return
+	}
+
+	mentions := []Mention{}
+	for i, r := range rows {
+		mention := Mention{Chirp: chirps[i], Read: r.ReadAt.Valid}
+		if r.ReadAt.Valid {
+			mention.ReadAt = &r.ReadAt.Time
+		}
+		mentions = append(mentions, mention)
+	}
+
+	respondWithJSON(writer, http.StatusOK, MentionPage{
+		Mentions:    mentions,
+		UnreadCount: unreadCount,
+		NextCursor:  nextCursor,
+		PrevCursor:  prevCursor,
+	})
+}
+
+// handlerMarkMentionsRead marks the listed chirps' mentions of the caller as
+// read, or every mention when no chirp IDs are given.
+func (cfg *apiConfig) handlerMarkMentionsRead(writer http.ResponseWriter, request *http.Request) {
+	type parameters struct {
+		ChirpIDs []uuid.UUID `json:"chirp_ids"`
+	}
+
+	userID, ok := cfg.requireUser(writer, request)
+	if !ok {
+		return
+	}
+
+	params := parameters{}
+	if request.ContentLength != 0 {
+		decoder := json.NewDecoder(request.Body)
+		err := decoder.Decode(&params)
+		if err != nil {
+			respondWithError(writer, http.StatusBadRequest, "Could not decode parameters", err)
+			return
+		}
+	}
+
+	var err error
+	if len(params.ChirpIDs) == 0 {
+		err = cfg.dbQueries.MarkAllMentionsRead(context.Background(), userID)
+	} else {
+		err = cfg.dbQueries.MarkMentionsRead(context.Background(), database.MarkMentionsReadParams{
+			UserID:   userID,
+			ChirpIds: params.ChirpIDs,
+		})
+	}
+	if err != nil {
+		respondWithError(writer, http.StatusInternalServerError, "Could not update mentions", err)
+		return
+	}
+
+	writer.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) listMentions(ctx context.Context, userID uuid.UUID, unreadOnly bool, page pageRequest) ([]mentionRow, error) {
+	cursorCreatedAt, cursorID := page.cursorParams()
+	rowLimit := int32(page.Limit + 1)
+
+	mentions := []mentionRow{}
+	if page.scanForward() {
+		rows, err := cfg.dbQueries.ListMentionsAsc(ctx, database.ListMentionsAscParams{
+			UserID:          userID,
+			UnreadOnly:      unreadOnly,
+			CursorCreatedAt: cursorCreatedAt,
+			CursorID:        cursorID,
+			RowLimit:        rowLimit,
+		})
+		for _, r := range rows {
+			mentions = append(mentions, mentionRow{Chirp: r.Chirp, ReadAt: r.ReadAt})
+		}
+		return mentions, err
+	}
+
+	rows, err := cfg.dbQueries.ListMentionsDesc(ctx, database.ListMentionsDescParams{
+		UserID:          userID,
+		UnreadOnly:      unreadOnly,
+		CursorCreatedAt: cursorCreatedAt,
+		CursorID:        cursorID,
+		RowLimit:        rowLimit,
+	})
+	for _, r := range rows {
+		mentions = append(mentions, mentionRow{Chirp: r.Chirp, ReadAt: r.ReadAt})
+	}
+	return mentions, err
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/tomanta/chirpy/internal/auth"
+	"github.com/tomanta/chirpy/internal/database"
+	"github.com/tomanta/chirpy/internal/mail"
+	"log"
+	"net/http"
+	"time"
+)
+
+const (
+	passwordResetTTL = time.Hour
+	// passwordResetCooldown is how long after one reset email another
+	// isn't sent, as long as the first token is still unused.
+	passwordResetCooldown = 15 * time.Minute
+)
+
+// Reset requests are throttled so the endpoint can't be used to flood an
+// inbox, per address asked for and per client.
+var passwordResetEmailThrottle = auth.LoginThrottle{
+	FreeAttempts: 2,
+	BaseDelay:    time.Minute,
+	MaxDelay:     time.Hour,
+}
+
+var passwordResetIPThrottle = auth.LoginThrottle{
+	FreeAttempts: 10,
+	BaseDelay:    time.Minute,
+	MaxDelay:     time.Hour,
+}
+
+// handlerForgotPassword answers 202 and does the lookup and mailing after
+// responding, so neither the status nor the timing reveals whether the
+// address has an account. Throttling goes by the address as typed, so it
+// doesn't give that away either.
+func (cfg *apiConfig) handlerForgotPassword(writer http.ResponseWriter, request *http.Request) {
+	type parameters struct {
+		Email string `json:"email"`
+	}
+
+	decoder := json.NewDecoder(request.Body)
+	params := parameters{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		respondWithError(writer, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+
+	emailKey := "reset:" + emailLoginKey(params.Email)
+	ipKey := "reset:" + ipLoginKey(request)
+	if !cfg.checkThrottle(writer, "Too many password reset requests, try again later", emailKey, ipKey) {
+		return
+	}
+
+	_, err = cfg.recordLoginFailure(context.Background(), emailKey, passwordResetEmailThrottle)
+	if err != nil {
+		log.Printf("Couldn't record password reset request: %s", err)
+	}
+	_, err = cfg.recordLoginFailure(context.Background(), ipKey, passwordResetIPThrottle)
+	if err != nil {
+		log.Printf("Couldn't record password reset request: %s", err)
+	}
+
+	writer.WriteHeader(http.StatusAccepted)
+
+	go func() {
+		err := cfg.sendPasswordResetEmail(context.Background(), params.Email)
+		if err != nil {
+			log.Printf("Couldn't send password reset email: %s", err)
+		}
+	}()
+}
+
+func (cfg *apiConfig) sendPasswordResetEmail(ctx context.Context, email string) error {
+	user, err := cfg.dbQueries.GetUserByEmail(ctx, email)
+	if err != nil {
+		// Unknown addresses get nothing.
+		return nil
+	}
+
+	// The last email's token still works; another would only add noise.
+	recent, err := cfg.dbQueries.HasRecentPasswordResetToken(ctx, database.HasRecentPasswordResetTokenParams{
+		UserID:       user.ID,
+		CreatedAfter: time.Now().UTC().Add(-passwordResetCooldown),
+	})
+	if err != nil {
+		return err
+	}
+	if recent {
+		return nil
+	}
+
+	token, err := auth.MakeRefreshToken()
+	if err != nil {
+		return err
+	}
+
+	err = cfg.dbQueries.CreatePasswordResetToken(ctx, database.CreatePasswordResetTokenParams{
+		TokenHash: auth.HashToken(token),
+		UserID:    user.ID,
+		ExpiresAt: time.Now().UTC().Add(passwordResetTTL),
+	})
+	if err != nil {
+		return err
+	}
+
+	return cfg.mailer.Send(ctx, mail.Message{
+		To:      user.Email,
+		Subject: "Reset your Chirpy password",
+		Body: fmt.Sprintf("Use this token to reset your password:\n\n%s\n\nIt expires in %s and works once. If you didn't ask for a reset, ignore this email.\n",
+			token, passwordResetTTL),
+	})
+}
+
+// handlerResetPassword consumes a reset token, sets the new password and
+// signs the user out everywhere by revoking their refresh tokens.
+func (cfg *apiConfig) handlerResetPassword(writer http.ResponseWriter, request *http.Request) {
+	type parameters struct {
+		Token    string `json:"token"`
+		Password string `json:"password"`
+	}
+
+	decoder := json.NewDecoder(request.Body)
+	params := parameters{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		respondWithError(writer, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+
+	if !cfg.checkPassword(writer, params.Password) {
+		return
+	}
+
+	pwHash, err := auth.HashPassword(params.Password)
+	if err != nil {
+		respondWithError(writer, http.StatusInternalServerError, "Could not hash password", err)
+		return
+	}
+
+	tx, err := cfg.db.BeginTx(context.Background(), nil)
+	if err != nil {
+		respondWithError(writer, http.StatusInternalServerError, "Couldn't reset password", err)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.dbQueries.WithTx(tx)
+
+	userID, err := qtx.ConsumePasswordResetToken(context.Background(), auth.HashToken(params.Token))
+	if err != nil {
+		respondWithError(writer, http.StatusBadRequest, "Invalid or expired token", err)
+		return
+	}
+
+	err = qtx.UpdateUserPassword(context.Background(), database.UpdateUserPasswordParams{
+		HashedPassword: pwHash,
+		ID:             userID,
+	})
+	if err != nil {
+		respondWithError(writer, http.StatusInternalServerError, "Couldn't reset password", err)
+		return
+	}
+
+	err = qtx.ExpirePasswordResetTokens(context.Background(), userID)
+	if err != nil {
+		respondWithError(writer, http.StatusInternalServerError, "Couldn't reset password", err)
+		return
+	}
+
+	err = revokeUserSessions(context.Background(), qtx, userID)
+	if err != nil {
+		respondWithError(writer, http.StatusInternalServerError, "Couldn't reset password", err)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(writer, http.StatusInternalServerError, "Couldn't reset password", err)
+		return
+	}
+
+	writer.WriteHeader(http.StatusNoContent)
+}
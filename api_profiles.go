@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/google/uuid"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Profile is the public view of a user. It must never carry the email
+// address.
+type Profile struct {
+	ID             uuid.UUID `json:"id"`
+	Username       string    `json:"username"`
+	DisplayName    string    `json:"display_name"`
+	Bio            string    `json:"bio"`
+	Location       string    `json:"location"`
+	CreatedAt      time.Time `json:"created_at"`
+	IsChirpyRed    bool      `json:"is_chirpy_red"`
+	FollowerCount  int64     `json:"follower_count"`
+	FollowingCount int64     `json:"following_count"`
+	ChirpCount     int64     `json:"chirp_count"`
+}
+
+func (cfg *apiConfig) handlerGetProfile(writer http.ResponseWriter, request *http.Request) {
+	username := strings.ToLower(request.PathValue("username"))
+
+	dbProfile, err := cfg.dbQueries.GetUserProfileByUsername(context.Background(), sql.NullString{String: username, Valid: true})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			respondWithError(writer, http.StatusNotFound, "Couldn't find user", err)
+			return
+		}
+		respondWithError(writer, http.StatusInternalServerError, "Couldn't retrieve user", err)
+		return
+	}
+
+	respondWithJSON(writer, http.StatusOK, Profile{
+		ID:             dbProfile.ID,
+		Username:       dbProfile.Username.String,
+		DisplayName:    dbProfile.DisplayName,
+		Bio:            dbProfile.Bio,
+		Location:       dbProfile.Location,
+		CreatedAt:      dbProfile.CreatedAt,
+		IsChirpyRed:    dbProfile.IsChirpyRed,
+		FollowerCount:  dbProfile.FollowerCount,
+		FollowingCount: dbProfile.FollowingCount,
+		ChirpCount:     dbProfile.ChirpCount,
+	})
+}
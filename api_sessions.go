@@ -0,0 +1,175 @@
+package main
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"github.com/tomanta/chirpy/internal/auth"
+	"github.com/tomanta/chirpy/internal/database"
+	"net"
+	"net/http"
+	"time"
+	"unicode/utf8"
+)
+
+const maxUserAgentLength = 512
+
+type Session struct {
+	ID         uuid.UUID `json:"id"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+	UserAgent  string    `json:"user_agent"`
+	IP         string    `json:"ip"`
+	Scopes     []string  `json:"scopes"`
+	Current    bool      `json:"current"`
+}
+
+// handlerGetSessions lists the devices the caller is signed in on, most
+// recently used first.
+func (cfg *apiConfig) handlerGetSessions(writer http.ResponseWriter, request *http.Request) {
+	claims, ok := cfg.requireSession(writer, request, auth.ScopeAccount)
+	if !ok {
+		return
+	}
+
+	dbSessions, err := cfg.dbQueries.ListActiveSessions(context.Background(), claims.UserID)
+	if err != nil {
+		respondWithError(writer, http.StatusInternalServerError, "Couldn't retrieve sessions", err)
+		return
+	}
+
+	sessions := []Session{}
+	for _, s := range dbSessions {
+		sessions = append(sessions, Session{
+			ID:         s.ID,
+			CreatedAt:  s.CreatedAt,
+			LastUsedAt: s.LastUsedAt,
+			UserAgent:  s.UserAgent,
+			IP:         s.Ip,
+			Scopes:     s.Scopes,
+			Current:    s.ID == claims.SessionID,
+		})
+	}
+
+	respondWithJSON(writer, http.StatusOK, sessions)
+}
+
+// handlerRevokeSession signs one device out. Its refresh token stops
+// working and so do any access tokens issued to it.
+func (cfg *apiConfig) handlerRevokeSession(writer http.ResponseWriter, request *http.Request) {
+	userID, ok := cfg.requireUser(writer, request, auth.ScopeAccount)
+	if !ok {
+		return
+	}
+
+	sessionID, err := uuid.Parse(request.PathValue("sessionID"))
+	if err != nil {
+		respondWithError(writer, http.StatusBadRequest, "Invalid session ID", err)
+		return
+	}
+
+	tx, err := cfg.db.BeginTx(context.Background(), nil)
+	if err != nil {
+		respondWithError(writer, http.StatusInternalServerError, "Couldn't revoke session", err)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.dbQueries.WithTx(tx)
+
+	revoked, err := revokeSession(context.Background(), qtx, userID, sessionID)
+	if err != nil {
+		respondWithError(writer, http.StatusInternalServerError, "Couldn't revoke session", err)
+		return
+	}
+	if !revoked {
+		respondWithError(writer, http.StatusNotFound, "Couldn't find session", nil)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(writer, http.StatusInternalServerError, "Couldn't revoke session", err)
+		return
+	}
+
+	writer.WriteHeader(http.StatusNoContent)
+}
+
+// handlerRevokeAllSessions signs the caller out everywhere, including the
+// device making the request, and revokes their personal access tokens.
+func (cfg *apiConfig) handlerRevokeAllSessions(writer http.ResponseWriter, request *http.Request) {
+	userID, ok := cfg.requireUser(writer, request, auth.ScopeAccount)
+	if !ok {
+		return
+	}
+
+	tx, err := cfg.db.BeginTx(context.Background(), nil)
+	if err != nil {
+		respondWithError(writer, http.StatusInternalServerError, "Couldn't revoke sessions", err)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.dbQueries.WithTx(tx)
+
+	err = revokeUserSessions(context.Background(), qtx, userID)
+	if err != nil {
+		respondWithError(writer, http.StatusInternalServerError, "Couldn't revoke sessions", err)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(writer, http.StatusInternalServerError, "Couldn't revoke sessions", err)
+		return
+	}
+
+	writer.WriteHeader(http.StatusNoContent)
+}
+
+// revokeSession ends one of the user's sessions along with its refresh
+// tokens, reporting whether it was still active.
+func revokeSession(ctx context.Context, q *database.Queries, userID, sessionID uuid.UUID) (bool, error) {
+	revoked, err := q.RevokeSession(ctx, database.RevokeSessionParams{
+		ID:     sessionID,
+		UserID: userID,
+	})
+	if err != nil || revoked == 0 {
+		return false, err
+	}
+	return true, q.RevokeSessionRefreshTokens(ctx, sessionID)
+}
+
+// revokeUserSessions ends every session the user has, along with their
+// refresh tokens and personal access tokens, so nothing issued before keeps
+// working.
+func revokeUserSessions(ctx context.Context, q *database.Queries, userID uuid.UUID) error {
+	err := q.RevokeUserSessions(ctx, userID)
+	if err != nil {
+		return err
+	}
+	err = q.RevokeUserRefreshTokens(ctx, userID)
+	if err != nil {
+		return err
+	}
+	return q.RevokeUserPersonalAccessTokens(ctx, userID)
+}
+
+// clientIP is the address the request came from. Chirpy isn't deployed
+// behind a proxy, so forwarding headers aren't trusted.
+func clientIP(request *http.Request) string {
+	host, _, err := net.SplitHostPort(request.RemoteAddr)
+	if err != nil {
+		return request.RemoteAddr
+	}
+	return host
+}
+
+// truncate cuts s to at most n bytes without splitting a UTF-8 sequence.
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
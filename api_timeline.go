@@ -0,0 +1,28 @@
+package main
+
+import (
+	"context"
+	"net/http"
+)
+
+func (cfg *apiConfig) handlerGetTimeline(writer http.ResponseWriter, request *http.Request) {
+	userID, ok := cfg.requireUser(writer, request)
+	if !ok {
+		return
+	}
+
+	// The timeline reads newest first unless the client asks otherwise.
+	page, err := parsePageRequest(request.URL.Query(), false)
+	if err != nil {
+		respondWithError(writer, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+
+	dbChirps, err := cfg.listTimeline(context.Background(), userID, page)
+	if err != nil {
+		respondWithError(writer, http.StatusInternalServerError, "Could not retrieve timeline", err)
+		return
+	}
+
+	cfg.respondWithChirpPage(writer, request, page, dbChirps)
+}
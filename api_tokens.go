@@ -0,0 +1,188 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"github.com/google/uuid"
+	"github.com/tomanta/chirpy/internal/auth"
+	"github.com/tomanta/chirpy/internal/database"
+	"log"
+	"net/http"
+	"slices"
+	"time"
+	"unicode/utf8"
+)
+
+const maxTokenNameLength = 100
+
+type PersonalAccessToken struct {
+	ID         uuid.UUID  `json:"id"`
+	Name       string     `json:"name"`
+	Scopes     []string   `json:"scopes"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	// Token is only ever set in the response that creates it.
+	Token string `json:"token,omitempty"`
+}
+
+func personalAccessTokenFromDB(t database.PersonalAccessToken) PersonalAccessToken {
+	token := PersonalAccessToken{
+		ID:        t.ID,
+		Name:      t.Name,
+		Scopes:    t.Scopes,
+		CreatedAt: t.CreatedAt,
+	}
+	if t.LastUsedAt.Valid {
+		token.LastUsedAt = &t.LastUsedAt.Time
+	}
+	if t.ExpiresAt.Valid {
+		token.ExpiresAt = &t.ExpiresAt.Time
+	}
+	return token
+}
+
+// handlerCreateToken issues a named personal access token for scripts and
+// bots. The plaintext is returned this once; only its digest is kept.
+func (cfg *apiConfig) handlerCreateToken(writer http.ResponseWriter, request *http.Request) {
+	type parameters struct {
+		Name   string   `json:"name"`
+		Scopes []string `json:"scopes"`
+		// ExpiresInDays of zero means the token never expires.
+		ExpiresInDays int `json:"expires_in_days"`
+	}
+
+	userID, ok := cfg.requireUser(writer, request, auth.ScopeAccount)
+	if !ok {
+		return
+	}
+
+	decoder := json.NewDecoder(request.Body)
+	params := parameters{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		respondWithError(writer, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+
+	if params.Name == "" || utf8.RuneCountInString(params.Name) > maxTokenNameLength {
+		respondWithError(writer, http.StatusBadRequest, "Token name must be between 1 and 100 characters", nil)
+		return
+	}
+
+	if params.ExpiresInDays < 0 {
+		respondWithError(writer, http.StatusBadRequest, "expires_in_days can't be negative", nil)
+		return
+	}
+
+	scopes, err := auth.ReduceScopes(auth.PersonalAccessTokenScopes, params.Scopes)
+	if err != nil {
+		respondWithError(writer, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+
+	plaintext, err := auth.MakePersonalAccessToken()
+	if err != nil {
+		respondWithError(writer, http.StatusInternalServerError, "Couldn't create token", err)
+		return
+	}
+
+	expiresAt := sql.NullTime{}
+	if params.ExpiresInDays > 0 {
+		expiresAt = sql.NullTime{Time: time.Now().UTC().AddDate(0, 0, params.ExpiresInDays), Valid: true}
+	}
+
+	dbToken, err := cfg.dbQueries.CreatePersonalAccessToken(context.Background(), database.CreatePersonalAccessTokenParams{
+		UserID:    userID,
+		Name:      params.Name,
+		TokenHash: auth.HashToken(plaintext),
+		Scopes:    auth.ScopeNames(scopes),
+		ExpiresAt: expiresAt,
+	})
+	if err != nil {
+		respondWithError(writer, http.StatusInternalServerError, "Couldn't create token", err)
+		return
+	}
+
+	token := personalAccessTokenFromDB(dbToken)
+	token.Token = plaintext
+	respondWithJSON(writer, http.StatusCreated, token)
+}
+
+func (cfg *apiConfig) handlerGetTokens(writer http.ResponseWriter, request *http.Request) {
+	userID, ok := cfg.requireUser(writer, request, auth.ScopeAccount)
+	if !ok {
+		return
+	}
+
+	dbTokens, err := cfg.dbQueries.ListPersonalAccessTokens(context.Background(), userID)
+	if err != nil {
+		respondWithError(writer, http.StatusInternalServerError, "Couldn't retrieve tokens", err)
+		return
+	}
+
+	tokens := []PersonalAccessToken{}
+	for _, t := range dbTokens {
+		tokens = append(tokens, personalAccessTokenFromDB(t))
+	}
+
+	respondWithJSON(writer, http.StatusOK, tokens)
+}
+
+func (cfg *apiConfig) handlerRevokeToken(writer http.ResponseWriter, request *http.Request) {
+	userID, ok := cfg.requireUser(writer, request, auth.ScopeAccount)
+	if !ok {
+		return
+	}
+
+	tokenID, err := uuid.Parse(request.PathValue("tokenID"))
+	if err != nil {
+		respondWithError(writer, http.StatusBadRequest, "Invalid token ID", err)
+		return
+	}
+
+	revoked, err := cfg.dbQueries.RevokePersonalAccessToken(context.Background(), database.RevokePersonalAccessTokenParams{
+		ID:     tokenID,
+		UserID: userID,
+	})
+	if err != nil {
+		respondWithError(writer, http.StatusInternalServerError, "Couldn't revoke token", err)
+		return
+	}
+	if revoked == 0 {
+		respondWithError(writer, http.StatusNotFound, "Couldn't find token", nil)
+		return
+	}
+
+	writer.WriteHeader(http.StatusNoContent)
+}
+
+// validatePersonalAccessToken looks a personal access token up by digest
+// and returns the claims it grants.
+func (cfg *apiConfig) validatePersonalAccessToken(ctx context.Context, token string) (auth.AccessClaims, error) {
+	dbToken, err := cfg.dbQueries.GetActivePersonalAccessToken(ctx, auth.HashToken(token))
+	if err != nil {
+		return auth.AccessClaims{}, err
+	}
+
+	err = cfg.dbQueries.TouchPersonalAccessToken(ctx, dbToken.ID)
+	if err != nil {
+		log.Printf("Couldn't update token %s: %s", dbToken.ID, err)
+	}
+
+	// Whatever the row says, a personal access token never gets more than
+	// PersonalAccessTokenScopes.
+	scopes := []auth.Scope{}
+	for _, scope := range auth.ScopesFromNames(dbToken.Scopes) {
+		if slices.Contains(auth.PersonalAccessTokenScopes, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+
+	return auth.AccessClaims{
+		UserID:  dbToken.UserID,
+		TokenID: dbToken.ID,
+		Scopes:  scopes,
+	}, nil
+}
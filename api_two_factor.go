@@ -0,0 +1,313 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"github.com/google/uuid"
+	"github.com/tomanta/chirpy/internal/auth"
+	"github.com/tomanta/chirpy/internal/database"
+	"net/http"
+	"time"
+)
+
+const (
+	totpIssuer            = "Chirpy"
+	recoveryCodeCount     = 10
+	twoFactorChallengeTTL = 5 * time.Minute
+)
+
+type TwoFactorEnrollment struct {
+	Secret        string   `json:"secret"`
+	OTPAuthURI    string   `json:"otpauth_uri"`
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// handlerEnrollTwoFactor starts (or restarts) TOTP enrollment. 2FA isn't
+// enforced until the user confirms a code from their authenticator.
+func (cfg *apiConfig) handlerEnrollTwoFactor(writer http.ResponseWriter, request *http.Request) {
+	userID, ok := cfg.requireUser(writer, request, auth.ScopeAccount)
+	if !ok {
+		return
+	}
+
+	user, err := cfg.dbQueries.GetUser(context.Background(), userID)
+	if err != nil {
+		respondWithError(writer, http.StatusNotFound, "Couldn't find user", err)
+		return
+	}
+
+	secret, err := auth.GenerateTOTPSecret()
+	if err != nil {
+		respondWithError(writer, http.StatusInternalServerError, "Couldn't enroll two-factor authentication", err)
+		return
+	}
+
+	recoveryCodes, err := auth.GenerateRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		respondWithError(writer, http.StatusInternalServerError, "Couldn't enroll two-factor authentication", err)
+		return
+	}
+
+	codeHashes := []string{}
+	for _, code := range recoveryCodes {
+		codeHashes = append(codeHashes, auth.HashToken(auth.NormalizeRecoveryCode(code)))
+	}
+
+	tx, err := cfg.db.BeginTx(context.Background(), nil)
+	if err != nil {
+		respondWithError(writer, http.StatusInternalServerError, "Couldn't enroll two-factor authentication", err)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.dbQueries.WithTx(tx)
+
+	existing, err := qtx.GetUserTOTP(context.Background(), userID)
+	if err == nil && existing.ConfirmedAt.Valid {
+		respondWithError(writer, http.StatusConflict, "Two-factor authentication is already enabled", nil)
+		return
+	}
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		respondWithError(writer, http.StatusInternalServerError, "Couldn't enroll two-factor authentication", err)
+		return
+	}
+
+	err = qtx.StartTOTPEnrollment(context.Background(), database.StartTOTPEnrollmentParams{
+		UserID: userID,
+		Secret: secret,
+	})
+	if err != nil {
+		respondWithError(writer, http.StatusInternalServerError, "Couldn't enroll two-factor authentication", err)
+		return
+	}
+
+	err = qtx.DeleteRecoveryCodes(context.Background(), userID)
+	if err != nil {
+		respondWithError(writer, http.StatusInternalServerError, "Couldn't enroll two-factor authentication", err)
+		return
+	}
+
+	err = qtx.CreateRecoveryCodes(context.Background(), database.CreateRecoveryCodesParams{
+		UserID:     userID,
+		CodeHashes: codeHashes,
+	})
+	if err != nil {
+		respondWithError(writer, http.StatusInternalServerError, "Couldn't enroll two-factor authentication", err)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(writer, http.StatusInternalServerError, "Couldn't enroll two-factor authentication", err)
+		return
+	}
+
+	respondWithJSON(writer, http.StatusCreated, TwoFactorEnrollment{
+		Secret:        secret,
+		OTPAuthURI:    auth.TOTPURI(totpIssuer, user.Email, secret),
+		RecoveryCodes: recoveryCodes,
+	})
+}
+
+func (cfg *apiConfig) handlerConfirmTwoFactor(writer http.ResponseWriter, request *http.Request) {
+	type parameters struct {
+		Code string `json:"code"`
+	}
+
+	userID, ok := cfg.requireUser(writer, request, auth.ScopeAccount)
+	if !ok {
+		return
+	}
+
+	decoder := json.NewDecoder(request.Body)
+	params := parameters{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		respondWithError(writer, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+
+	twoFactor, err := cfg.dbQueries.GetUserTOTP(context.Background(), userID)
+	if err != nil {
+		respondWithError(writer, http.StatusNotFound, "No two-factor enrollment in progress", err)
+		return
+	}
+
+	if twoFactor.ConfirmedAt.Valid {
+		respondWithError(writer, http.StatusConflict, "Two-factor authentication is already enabled", nil)
+		return
+	}
+
+	step, err := auth.ValidateTOTP(twoFactor.Secret, params.Code, time.Now())
+	if err != nil {
+		respondWithError(writer, http.StatusBadRequest, "Invalid code", err)
+		return
+	}
+
+	err = cfg.dbQueries.ConfirmTOTP(context.Background(), database.ConfirmTOTPParams{
+		Step:   step,
+		UserID: userID,
+	})
+	if err != nil {
+		respondWithError(writer, http.StatusInternalServerError, "Couldn't enable two-factor authentication", err)
+		return
+	}
+
+	writer.WriteHeader(http.StatusNoContent)
+}
+
+// handlerDisableTwoFactor turns 2FA off. It asks for the password again so a
+// stolen access token alone can't strip the second factor.
+func (cfg *apiConfig) handlerDisableTwoFactor(writer http.ResponseWriter, request *http.Request) {
+	type parameters struct {
+		Password string `json:"password"`
+	}
+
+	userID, ok := cfg.requireUser(writer, request, auth.ScopeAccount)
+	if !ok {
+		return
+	}
+
+	decoder := json.NewDecoder(request.Body)
+	params := parameters{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		respondWithError(writer, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+
+	user, err := cfg.dbQueries.GetUserByID(context.Background(), userID)
+	if err != nil {
+		respondWithError(writer, http.StatusNotFound, "Couldn't find user", err)
+		return
+	}
+
+	err = auth.CheckPasswordHash(params.Password, user.HashedPassword)
+	if err != nil {
+		respondWithError(writer, http.StatusForbidden, "Password is incorrect", err)
+		return
+	}
+
+	tx, err := cfg.db.BeginTx(context.Background(), nil)
+	if err != nil {
+		respondWithError(writer, http.StatusInternalServerError, "Couldn't disable two-factor authentication", err)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.dbQueries.WithTx(tx)
+
+	err = qtx.DeleteUserTOTP(context.Background(), userID)
+	if err != nil {
+		respondWithError(writer, http.StatusInternalServerError, "Couldn't disable two-factor authentication", err)
+		return
+	}
+
+	err = qtx.DeleteRecoveryCodes(context.Background(), userID)
+	if err != nil {
+		respondWithError(writer, http.StatusInternalServerError, "Couldn't disable two-factor authentication", err)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(writer, http.StatusInternalServerError, "Couldn't disable two-factor authentication", err)
+		return
+	}
+
+	writer.WriteHeader(http.StatusNoContent)
+}
+
+// handlerLoginTwoFactor redeems a login challenge with either a TOTP code
+// or one of the recovery codes, and issues the usual token pair.
+func (cfg *apiConfig) handlerLoginTwoFactor(writer http.ResponseWriter, request *http.Request) {
+	type parameters struct {
+		ChallengeToken string   `json:"challenge_token"`
+		Code           string   `json:"code"`
+		RecoveryCode   string   `json:"recovery_code"`
+		Scopes         []string `json:"scopes"`
+	}
+
+	decoder := json.NewDecoder(request.Body)
+	params := parameters{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		respondWithError(writer, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+
+	userID, err := auth.ValidateTwoFactorChallenge(params.ChallengeToken, cfg.jwtKeys)
+	if err != nil {
+		respondWithError(writer, http.StatusUnauthorized, "Invalid or expired challenge", err)
+		return
+	}
+
+	accountKey := twoFactorLoginKey(userID)
+	if !cfg.checkLoginLock(writer, request, accountKey) {
+		return
+	}
+	failedFor := uuid.NullUUID{UUID: userID, Valid: true}
+
+	user, err := cfg.dbQueries.GetUserByID(context.Background(), userID)
+	if err != nil {
+		respondWithError(writer, http.StatusUnauthorized, "Couldn't find user", err)
+		return
+	}
+
+	// Checked before a code is spent on it.
+	scopes, err := loginScopes(user, params.Scopes)
+	if err != nil {
+		respondWithError(writer, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+
+	twoFactor, err := cfg.dbQueries.GetUserTOTP(context.Background(), userID)
+	if err != nil || !twoFactor.ConfirmedAt.Valid {
+		respondWithError(writer, http.StatusUnauthorized, "Two-factor authentication is not enabled", err)
+		return
+	}
+
+	switch {
+	case params.Code != "":
+		step, err := auth.ValidateTOTP(twoFactor.Secret, params.Code, time.Now())
+		if err != nil {
+			cfg.loginFailed(request, accountKey, failedFor)
+			respondWithError(writer, http.StatusUnauthorized, "Invalid code", err)
+			return
+		}
+
+		// Each code works once, even inside its validity window.
+		used, err := cfg.dbQueries.UseTOTPStep(context.Background(), database.UseTOTPStepParams{
+			Step:   step,
+			UserID: userID,
+		})
+		if err != nil {
+			respondWithError(writer, http.StatusInternalServerError, "Couldn't verify code", err)
+			return
+		}
+		if used == 0 {
+			cfg.loginFailed(request, accountKey, failedFor)
+			respondWithError(writer, http.StatusUnauthorized, "Code has already been used", nil)
+			return
+		}
+	case params.RecoveryCode != "":
+		used, err := cfg.dbQueries.UseRecoveryCode(context.Background(), database.UseRecoveryCodeParams{
+			UserID:   userID,
+			CodeHash: auth.HashToken(auth.NormalizeRecoveryCode(params.RecoveryCode)),
+		})
+		if err != nil {
+			respondWithError(writer, http.StatusInternalServerError, "Couldn't verify code", err)
+			return
+		}
+		if used == 0 {
+			cfg.loginFailed(request, accountKey, failedFor)
+			respondWithError(writer, http.StatusUnauthorized, "Invalid recovery code", nil)
+			return
+		}
+	default:
+		respondWithError(writer, http.StatusBadRequest, "A code or recovery code is required", nil)
+		return
+	}
+
+	cfg.respondWithLogin(writer, request, user, scopes)
+}
@@ -6,23 +6,131 @@ import (
 	"encoding/json"
 	"errors"
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 	"github.com/tomanta/chirpy/internal/auth"
 	"github.com/tomanta/chirpy/internal/database"
+	"log"
+	"mime"
 	"net/http"
+	"regexp"
+	"strings"
 	"time"
+	"unicode/utf8"
 )
 
+const (
+	minUsernameLength    = 3
+	maxUsernameLength    = 30
+	maxDisplayNameLength = 50
+	maxBioLength         = 160
+	maxLocationLength    = 30
+)
+
+// Usernames are stored lower-cased so lookups and @mentions are
+// case-insensitive.
+var usernamePattern = regexp.MustCompile(`^[a-z0-9_]+$`)
+
 type User struct {
-	Id          uuid.UUID `json:"id"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
-	Email       string    `json:"email"`
-	IsChirpyRed bool      `json:"is_chirpy_red"`
+	Id            uuid.UUID `json:"id"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+	Email         string    `json:"email"`
+	Username      string    `json:"username"`
+	DisplayName   string    `json:"display_name"`
+	Bio           string    `json:"bio"`
+	Location      string    `json:"location"`
+	EmailVerified bool      `json:"email_verified"`
+	IsChirpyRed   bool      `json:"is_chirpy_red"`
 }
 
 type UserParameters struct {
-	Email    string `json:"email"`
-	Password string `json:"password"`
+	Email       string  `json:"email"`
+	Password    string  `json:"password"`
+	Username    *string `json:"username"`
+	DisplayName *string `json:"display_name"`
+	Bio         *string `json:"bio"`
+	Location    *string `json:"location"`
+}
+
+func userFromDB(u database.User) User {
+	return User{
+		Id:            u.ID,
+		CreatedAt:     u.CreatedAt,
+		UpdatedAt:     u.UpdatedAt,
+		Email:         u.Email,
+		Username:      u.Username.String,
+		DisplayName:   u.DisplayName,
+		Bio:           u.Bio,
+		Location:      u.Location,
+		EmailVerified: u.EmailVerified,
+		IsChirpyRed:   u.IsChirpyRed,
+	}
+}
+
+// validateProfile checks the profile fields that were supplied and
+// normalizes the username in place.
+func validateProfile(params *UserParameters) error {
+	if isReservedEmail(params.Email) {
+		return errors.New("Email addresses under .invalid are reserved")
+	}
+	if params.Username != nil {
+		username := strings.ToLower(strings.TrimSpace(*params.Username))
+		if len(username) < minUsernameLength || len(username) > maxUsernameLength || !usernamePattern.MatchString(username) {
+			return errors.New("Username must be 3-30 letters, digits or underscores")
+		}
+		params.Username = &username
+	}
+	if params.DisplayName != nil && utf8.RuneCountInString(*params.DisplayName) > maxDisplayNameLength {
+		return errors.New("Display name is too long")
+	}
+	if params.Bio != nil && utf8.RuneCountInString(*params.Bio) > maxBioLength {
+		return errors.New("Bio is too long")
+	}
+	if params.Location != nil && utf8.RuneCountInString(*params.Location) > maxLocationLength {
+		return errors.New("Location is too long")
+	}
+	return nil
+}
+
+// isReservedEmail reports whether email is under the reserved .invalid
+// domain, which the users table refuses so the deleted-user placeholder
+// keeps its address.
+func isReservedEmail(email string) bool {
+	return strings.HasSuffix(strings.ToLower(strings.TrimSpace(email)), ".invalid")
+}
+
+// checkPassword holds a new password to the password policy, responding
+// with a field error for each rule it breaks.
+func (cfg *apiConfig) checkPassword(writer http.ResponseWriter, password string) bool {
+	err := cfg.passwordPolicy.Validate(password)
+	var policyErr *auth.PasswordPolicyError
+	if errors.As(err, &policyErr) {
+		fields := []fieldError{}
+		for _, v := range policyErr.Violations {
+			fields = append(fields, fieldError{Field: "password", Code: v.Code, Message: v.Message})
+		}
+		respondWithFieldErrors(writer, policyErr.Error(), fields)
+		return false
+	}
+	if err != nil {
+		respondWithError(writer, http.StatusInternalServerError, "Couldn't check password", err)
+		return false
+	}
+	return true
+}
+
+func nullString(s *string) sql.NullString {
+	if s == nil {
+		return sql.NullString{}
+	}
+	return sql.NullString{String: *s, Valid: true}
+}
+
+// isUniqueViolation reports whether err was raised by the named unique
+// constraint.
+func isUniqueViolation(err error, constraint string) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505" && pqErr.Constraint == constraint
 }
 
 func (cfg *apiConfig) handlerCreateUser(writer http.ResponseWriter, request *http.Request) {
@@ -37,6 +145,16 @@ func (cfg *apiConfig) handlerCreateUser(writer http.ResponseWriter, request *htt
 		return
 	}
 
+	err = validateProfile(&params)
+	if err != nil {
+		respondWithError(writer, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+
+	if !cfg.checkPassword(writer, params.Password) {
+		return
+	}
+
 	pw_hash, err := auth.HashPassword(params.Password)
 	if err != nil {
 		respondWithError(writer, http.StatusInternalServerError, "Could not hash password", err)
@@ -46,46 +164,78 @@ func (cfg *apiConfig) handlerCreateUser(writer http.ResponseWriter, request *htt
 	user_params := database.CreateUserParams{
 		Email:          params.Email,
 		HashedPassword: pw_hash,
+		Username:       nullString(params.Username),
+		DisplayName:    nullString(params.DisplayName).String,
+		Bio:            nullString(params.Bio).String,
+		Location:       nullString(params.Location).String,
 	}
 
 	returnUser, err := cfg.dbQueries.CreateUser(context.Background(), user_params)
 	if err != nil {
+		if isUniqueViolation(err, "users_username_key") {
+			respondWithError(writer, http.StatusConflict, "Username is already taken", err)
+			return
+		}
 		respondWithError(writer, http.StatusInternalServerError, "Couldn't create user", err)
 		return
 	}
 
-	payload := User{
-		Id:          returnUser.ID,
-		CreatedAt:   returnUser.CreatedAt,
-		UpdatedAt:   returnUser.UpdatedAt,
-		Email:       returnUser.Email,
-		IsChirpyRed: returnUser.IsChirpyRed,
+	// The account exists either way; the user can ask for another email.
+	err = cfg.sendVerificationEmail(context.Background(), returnUser.ID, returnUser.Email)
+	if err != nil {
+		log.Printf("Couldn't send verification email: %s", err)
 	}
 
-	respondWithJSON(writer, http.StatusCreated, payload)
+	respondWithJSON(writer, http.StatusCreated, userFromDB(returnUser))
 }
 
+// handlerUpdateUser replaces the whole account, email and password
+// included, so it needs the account scope and the current password.
 func (cfg *apiConfig) handlerUpdateUser(writer http.ResponseWriter, request *http.Request) {
+	type parameters struct {
+		UserParameters
+		CurrentPassword string `json:"current_password"`
+	}
+
+	userID, ok := cfg.requireUser(writer, request, auth.ScopeProfileWrite, auth.ScopeAccount)
+	if !ok {
+		return
+	}
+
+	decoder := json.NewDecoder(request.Body)
+	params := parameters{}
+	err := decoder.Decode(&params)
 
-	token, err := auth.GetBearerToken(request.Header)
+	// Error: Unable to decode
 	if err != nil {
-		respondWithError(writer, http.StatusUnauthorized, "Could not find JWT", err)
+		respondWithError(writer, http.StatusInternalServerError, "Couldn't decode parameters", err)
 		return
 	}
 
-	userID, err := auth.ValidateJWT(token, cfg.jwtSecret)
+	err = validateProfile(&params.UserParameters)
 	if err != nil {
-		respondWithError(writer, http.StatusUnauthorized, "Could not validate JWT", err)
+		respondWithError(writer, http.StatusBadRequest, err.Error(), nil)
 		return
 	}
 
-	decoder := json.NewDecoder(request.Body)
-	params := UserParameters{}
-	err = decoder.Decode(&params)
+	if params.CurrentPassword == "" {
+		respondWithError(writer, http.StatusBadRequest, "Current password is required", nil)
+		return
+	}
 
-	// Error: Unable to decode
+	current_user, err := cfg.dbQueries.GetUserByID(context.Background(), userID)
 	if err != nil {
-		respondWithError(writer, http.StatusInternalServerError, "Couldn't decode parameters", err)
+		respondWithError(writer, http.StatusNotFound, "Couldn't find user", err)
+		return
+	}
+
+	err = auth.CheckPasswordHash(params.CurrentPassword, current_user.HashedPassword)
+	if err != nil {
+		respondWithError(writer, http.StatusForbidden, "Current password is incorrect", err)
+		return
+	}
+
+	if !cfg.checkPassword(writer, params.Password) {
 		return
 	}
 
@@ -95,32 +245,212 @@ func (cfg *apiConfig) handlerUpdateUser(writer http.ResponseWriter, request *htt
 		return
 	}
 
+	// Profile fields the client leaves out keep their current values.
 	user_params := database.UpdateUserParams{
 		Email:          params.Email,
 		HashedPassword: pw_hash,
+		Username:       nullString(params.Username),
+		DisplayName:    nullString(params.DisplayName),
+		Bio:            nullString(params.Bio),
+		Location:       nullString(params.Location),
 		ID:             userID,
 	}
 
 	updated_user, err := cfg.dbQueries.UpdateUser(context.Background(), user_params)
 	if err != nil {
+		if isUniqueViolation(err, "users_username_key") {
+			respondWithError(writer, http.StatusConflict, "Username is already taken", err)
+			return
+		}
 		respondWithError(writer, http.StatusUnauthorized, "Could not hash password", err)
 		return
 	}
 
-	type Payload struct {
-		Updated_at  time.Time `json:"updated_at"`
-		User_id     uuid.UUID `json:"id"`
-		Email       string    `json:"email"`
-		IsChirpyRed bool      `json:"is_chirpy_red"`
+	if updated_user.Email != current_user.Email {
+		err = cfg.sendVerificationEmail(context.Background(), updated_user.ID, updated_user.Email)
+		if err != nil {
+			log.Printf("Couldn't send verification email: %s", err)
+		}
+	}
+
+	respondWithJSON(writer, http.StatusOK, userFromDB(updated_user))
+
+}
+
+// handlerPatchUser applies a JSON Merge Patch (RFC 7386) to the caller's
+// account. Only the fields present in the patch change, and null clears an
+// optional profile field. Changing the email or password also needs the
+// account scope.
+func (cfg *apiConfig) handlerPatchUser(writer http.ResponseWriter, request *http.Request) {
+	claims, ok := cfg.requireSession(writer, request, auth.ScopeProfileWrite)
+	if !ok {
+		return
+	}
+	userID := claims.UserID
+
+	if contentType := request.Header.Get("Content-Type"); contentType != "" {
+		mediaType, _, err := mime.ParseMediaType(contentType)
+		if err != nil || (mediaType != "application/merge-patch+json" && mediaType != "application/json") {
+			respondWithError(writer, http.StatusUnsupportedMediaType, "Expected application/merge-patch+json", err)
+			return
+		}
 	}
 
-	respondWithJSON(writer, http.StatusOK, Payload{
-		Updated_at:  updated_user.UpdatedAt,
-		User_id:     updated_user.ID,
-		Email:       updated_user.Email,
-		IsChirpyRed: updated_user.IsChirpyRed,
-	})
+	patch := map[string]json.RawMessage{}
+	decoder := json.NewDecoder(request.Body)
+	err := decoder.Decode(&patch)
+	if err != nil {
+		respondWithError(writer, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+
+	// current_password isn't part of the account; it only authorizes a
+	// password change.
+	values := map[string]*string{}
+	for key, raw := range patch {
+		switch key {
+		case "email", "password", "current_password", "username", "display_name", "bio", "location":
+		default:
+			respondWithError(writer, http.StatusBadRequest, "Unknown field "+key, nil)
+			return
+		}
+
+		var value *string
+		err = json.Unmarshal(raw, &value)
+		if err != nil {
+			respondWithError(writer, http.StatusBadRequest, "Invalid value for "+key, err)
+			return
+		}
+		values[key] = value
+	}
+
+	_, changesEmail := values["email"]
+	_, changesPassword := values["password"]
+	if (changesEmail || changesPassword) && !requireScopes(writer, claims, auth.ScopeAccount) {
+		return
+	}
+
+	profile := UserParameters{
+		Username:    values["username"],
+		DisplayName: values["display_name"],
+		Bio:         values["bio"],
+		Location:    values["location"],
+	}
+	if email := values["email"]; email != nil {
+		profile.Email = *email
+	}
+	err = validateProfile(&profile)
+	if err != nil {
+		respondWithError(writer, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+
+	tx, err := cfg.db.BeginTx(context.Background(), nil)
+	if err != nil {
+		respondWithError(writer, http.StatusInternalServerError, "Couldn't update user", err)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.dbQueries.WithTx(tx)
+
+	user, err := qtx.GetUserByIDForUpdate(context.Background(), userID)
+	if err != nil {
+		respondWithError(writer, http.StatusNotFound, "Couldn't find user", err)
+		return
+	}
+
+	update := database.PatchUserParams{
+		Email:          user.Email,
+		HashedPassword: user.HashedPassword,
+		Username:       user.Username,
+		DisplayName:    user.DisplayName,
+		Bio:            user.Bio,
+		Location:       user.Location,
+		EmailVerified:  user.EmailVerified,
+		ID:             user.ID,
+	}
+
+	if email, ok := values["email"]; ok {
+		if email == nil || strings.TrimSpace(*email) == "" {
+			respondWithError(writer, http.StatusBadRequest, "Email cannot be removed", nil)
+			return
+		}
+		if *email != user.Email {
+			update.Email = *email
+			update.EmailVerified = false
+		}
+	}
+
+	if password, ok := values["password"]; ok {
+		if password == nil || *password == "" {
+			respondWithError(writer, http.StatusBadRequest, "Password cannot be removed", nil)
+			return
+		}
+
+		currentPassword := values["current_password"]
+		if currentPassword == nil {
+			respondWithError(writer, http.StatusBadRequest, "Current password is required", nil)
+			return
+		}
+
+		err = auth.CheckPasswordHash(*currentPassword, user.HashedPassword)
+		if err != nil {
+			respondWithError(writer, http.StatusForbidden, "Current password is incorrect", err)
+			return
+		}
+
+		if !cfg.checkPassword(writer, *password) {
+			return
+		}
+
+		update.HashedPassword, err = auth.HashPassword(*password)
+		if err != nil {
+			respondWithError(writer, http.StatusInternalServerError, "Could not hash password", err)
+			return
+		}
+	}
+
+	if _, ok := values["username"]; ok {
+		update.Username = nullString(profile.Username)
+	}
+	if _, ok := values["display_name"]; ok {
+		update.DisplayName = nullString(profile.DisplayName).String
+	}
+	if _, ok := values["bio"]; ok {
+		update.Bio = nullString(profile.Bio).String
+	}
+	if _, ok := values["location"]; ok {
+		update.Location = nullString(profile.Location).String
+	}
+
+	updatedUser, err := qtx.PatchUser(context.Background(), update)
+	if err != nil {
+		if isUniqueViolation(err, "users_username_key") {
+			respondWithError(writer, http.StatusConflict, "Username is already taken", err)
+			return
+		}
+		if isUniqueViolation(err, "users_email_key") {
+			respondWithError(writer, http.StatusConflict, "Email is already in use", err)
+			return
+		}
+		respondWithError(writer, http.StatusInternalServerError, "Couldn't update user", err)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(writer, http.StatusInternalServerError, "Couldn't update user", err)
+		return
+	}
+
+	if updatedUser.Email != user.Email {
+		err = cfg.sendVerificationEmail(context.Background(), updatedUser.ID, updatedUser.Email)
+		if err != nil {
+			log.Printf("Couldn't send verification email: %s", err)
+		}
+	}
 
+	respondWithJSON(writer, http.StatusOK, userFromDB(updatedUser))
 }
 
 func (cfg *apiConfig) handlerUpgradeRed(writer http.ResponseWriter, request *http.Request) {
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"github.com/tomanta/chirpy/internal/database"
+	"log"
+	"net/http"
+)
+
+const (
+	auditRefreshTokenReuse = "refresh_token_reuse"
+	auditAccountLocked     = "account_locked"
+)
+
+// recordAuditEvent keeps a security-relevant event in the audit trail.
+// Failing to record one shouldn't fail the request, so errors are only
+// logged.
+func (cfg *apiConfig) recordAuditEvent(ctx context.Context, request *http.Request, event string, userID, sessionID uuid.NullUUID) {
+	log.Printf("Audit: %s user=%s session=%s ip=%s", event, userID.UUID, sessionID.UUID, clientIP(request))
+
+	err := cfg.dbQueries.CreateAuditEvent(ctx, database.CreateAuditEventParams{
+		Event:     event,
+		UserID:    userID,
+		SessionID: sessionID,
+		Ip:        clientIP(request),
+		UserAgent: truncate(request.UserAgent(), maxUserAgentLength),
+	})
+	if err != nil {
+		log.Printf("Couldn't record audit event %s: %s", event, err)
+	}
+}
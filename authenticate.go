@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/tomanta/chirpy/internal/auth"
+	"github.com/tomanta/chirpy/internal/database"
+	"net/http"
+)
+
+// validateAccessToken checks an access token and that the session it was
+// issued for hasn't been revoked, so signing a device out takes effect
+// before its access token expires. Personal access tokens are accepted
+// anywhere an access token is.
+func (cfg *apiConfig) validateAccessToken(ctx context.Context, token string) (auth.AccessClaims, error) {
+	if auth.IsPersonalAccessToken(token) {
+		return cfg.validatePersonalAccessToken(ctx, token)
+	}
+
+	claims, err := auth.ValidateAccessToken(token, cfg.jwtKeys)
+	if err != nil {
+		return auth.AccessClaims{}, err
+	}
+
+	_, err = cfg.dbQueries.GetActiveSession(ctx, database.GetActiveSessionParams{
+		ID:     claims.SessionID,
+		UserID: claims.UserID,
+	})
+	if err != nil {
+		return auth.AccessClaims{}, err
+	}
+	return claims, nil
+}
+
+// requireSession authenticates the request and checks its token carries
+// every one of scopes, responding with a 401 or 403 and returning false if
+// not.
+func (cfg *apiConfig) requireSession(writer http.ResponseWriter, request *http.Request, scopes ...auth.Scope) (auth.AccessClaims, bool) {
+	token, err := auth.GetBearerToken(request.Header)
+	if err != nil {
+		respondWithError(writer, http.StatusUnauthorized, "Could not find JWT", err)
+		return auth.AccessClaims{}, false
+	}
+
+	claims, err := cfg.validateAccessToken(request.Context(), token)
+	if err != nil {
+		respondWithError(writer, http.StatusUnauthorized, "Could not validate JWT", err)
+		return auth.AccessClaims{}, false
+	}
+
+	if !requireScopes(writer, claims, scopes...) {
+		return auth.AccessClaims{}, false
+	}
+	return claims, true
+}
+
+// requireScopes responds with a 403 and returns false unless claims carries
+// every one of scopes. It's for handlers whose needs depend on the request,
+// on top of what requireSession already checked.
+func requireScopes(writer http.ResponseWriter, claims auth.AccessClaims, scopes ...auth.Scope) bool {
+	err := claims.RequireScopes(scopes...)
+	if err != nil {
+		writer.Header().Set("WWW-Authenticate", fmt.Sprintf("Bearer error=\"insufficient_scope\", scope=%q", auth.FormatScopes(scopes)))
+		respondWithError(writer, http.StatusForbidden, "Token is missing a required scope", err)
+		return false
+	}
+	return true
+}
+
+func (cfg *apiConfig) requireUser(writer http.ResponseWriter, request *http.Request, scopes ...auth.Scope) (uuid.UUID, bool) {
+	claims, ok := cfg.requireSession(writer, request, scopes...)
+	return claims.UserID, ok
+}
+
+// requireAdmin lets through only tokens with the admin scope whose user is
+// still an admin, so taking the flag away takes effect straight away.
+func (cfg *apiConfig) requireAdmin(writer http.ResponseWriter, request *http.Request) bool {
+	userID, ok := cfg.requireUser(writer, request, auth.ScopeAdmin)
+	if !ok {
+		return false
+	}
+
+	user, err := cfg.dbQueries.GetUserByID(request.Context(), userID)
+	if err != nil || !user.IsAdmin {
+		respondWithError(writer, http.StatusForbidden, "Admin access required", err)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"github.com/tomanta/chirpy/internal/database"
+	"time"
+)
+
+// chirpFilter narrows a chirp listing. The zero value lists every chirp.
+type chirpFilter struct {
+	AuthorID uuid.NullUUID
+}
+
+// listChirps fetches one page of chirps plus one look-ahead row. Filtering,
+// ordering and the limit all run in Postgres; pageCursors trims the extra row.
+func (cfg *apiConfig) listChirps(ctx context.Context, filter chirpFilter, page pageRequest) ([]database.Chirp, error) {
+	cursorCreatedAt, cursorID := page.cursorParams()
+	rowLimit := int32(page.Limit + 1)
+
+	if filter.AuthorID.Valid {
+		if page.scanForward() {
+			return cfg.dbQueries.ListChirpsByAuthorAsc(ctx, database.ListChirpsByAuthorAscParams{
+				AuthorID:        filter.AuthorID.UUID,
+				CursorCreatedAt: cursorCreatedAt,
+				CursorID:        cursorID,
+				RowLimit:        rowLimit,
+			})
+		}
+		return cfg.dbQueries.ListChirpsByAuthorDesc(ctx, database.ListChirpsByAuthorDescParams{
+			AuthorID:        filter.AuthorID.UUID,
+			CursorCreatedAt: cursorCreatedAt,
+			CursorID:        cursorID,
+			RowLimit:        rowLimit,
+		})
+	}
+
+	if page.scanForward() {
+		return cfg.dbQueries.ListChirpsAsc(ctx, database.ListChirpsAscParams{
+			CursorCreatedAt: cursorCreatedAt,
+			CursorID:        cursorID,
+			RowLimit:        rowLimit,
+		})
+	}
+	return cfg.dbQueries.ListChirpsDesc(ctx, database.ListChirpsDescParams{
+		CursorCreatedAt: cursorCreatedAt,
+		CursorID:        cursorID,
+		RowLimit:        rowLimit,
+	})
+}
+
+// listReplies pages through the direct replies to parentID, tombstones
+// included, with the same look-ahead row as listChirps.
+func (cfg *apiConfig) listReplies(ctx context.Context, parentID uuid.UUID, page pageRequest) ([]database.Chirp, error) {
+	cursorCreatedAt, cursorID := page.cursorParams()
+	rowLimit := int32(page.Limit + 1)
+
+	if page.scanForward() {
+		return cfg.dbQueries.ListRepliesAsc(ctx, database.ListRepliesAscParams{
+			ParentID:        parentID,
+			CursorCreatedAt: cursorCreatedAt,
+			CursorID:        cursorID,
+			RowLimit:        rowLimit,
+		})
+	}
+	return cfg.dbQueries.ListRepliesDesc(ctx, database.ListRepliesDescParams{
+		ParentID:        parentID,
+		CursorCreatedAt: cursorCreatedAt,
+		CursorID:        cursorID,
+		RowLimit:        rowLimit,
+	})
+}
+
+// listTimeline pages through the home timeline of userID: their own chirps
+// and those of everyone they follow.
+func (cfg *apiConfig) listTimeline(ctx context.Context, userID uuid.UUID, page pageRequest) ([]database.Chirp, error) {
+	cursorCreatedAt, cursorID := page.cursorParams()
+	rowLimit := int32(page.Limit + 1)
+
+	if page.scanForward() {
+		return cfg.dbQueries.ListTimelineAsc(ctx, database.ListTimelineAscParams{
+			UserID:          userID,
+			CursorCreatedAt: cursorCreatedAt,
+			CursorID:        cursorID,
+			RowLimit:        rowLimit,
+		})
+	}
+	return cfg.dbQueries.ListTimelineDesc(ctx, database.ListTimelineDescParams{
+		UserID:          userID,
+		CursorCreatedAt: cursorCreatedAt,
+		CursorID:        cursorID,
+		RowLimit:        rowLimit,
+	})
+}
+
+type searchHit struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	Rank      float32
+}
+
+// searchChirps pages through full-text matches for query, best match first,
+// with the same look-ahead row as listChirps.
+func (cfg *apiConfig) searchChirps(ctx context.Context, query string, filter chirpFilter, page pageRequest) ([]searchHit, error) {
+	cursorCreatedAt, cursorID := page.cursorParams()
+	rowLimit := int32(page.Limit + 1)
+
+	hits := []searchHit{}
+	if page.scanForward() {
+		rows, err := cfg.dbQueries.SearchChirpsAsc(ctx, database.SearchChirpsAscParams{
+			Query:           query,
+			AuthorID:        filter.AuthorID,
+			CursorRank:      page.cursorRank(),
+			CursorCreatedAt: cursorCreatedAt,
+			CursorID:        cursorID,
+			RowLimit:        rowLimit,
+		})
+		for _, r := range rows {
+			hits = append(hits, searchHit{ID: r.ID, CreatedAt: r.CreatedAt, Rank: r.Rank})
+		}
+		return hits, err
+	}
+
+	rows, err := cfg.dbQueries.SearchChirpsDesc(ctx, database.SearchChirpsDescParams{
+		Query:           query,
+		AuthorID:        filter.AuthorID,
+		CursorRank:      page.cursorRank(),
+		CursorCreatedAt: cursorCreatedAt,
+		CursorID:        cursorID,
+		RowLimit:        rowLimit,
+	})
+	for _, r := range rows {
+		hits = append(hits, searchHit{ID: r.ID, CreatedAt: r.CreatedAt, Rank: r.Rank})
+	}
+	return hits, err
+}
+
+// listHashtagChirps pages through the live chirps tagged with tag.
+func (cfg *apiConfig) listHashtagChirps(ctx context.Context, tag string, page pageRequest) ([]database.Chirp, error) {
+	cursorCreatedAt, cursorID := page.cursorParams()
+	rowLimit := int32(page.Limit + 1)
+
+	if page.scanForward() {
+		return cfg.dbQueries.ListHashtagChirpsAsc(ctx, database.ListHashtagChirpsAscParams{
+			Tag:             tag,
+			CursorCreatedAt: cursorCreatedAt,
+			CursorID:        cursorID,
+			RowLimit:        rowLimit,
+		})
+	}
+	return cfg.dbQueries.ListHashtagChirpsDesc(ctx, database.ListHashtagChirpsDescParams{
+		Tag:             tag,
+		CursorCreatedAt: cursorCreatedAt,
+		CursorID:        cursorID,
+		RowLimit:        rowLimit,
+	})
+}
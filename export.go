@@ -0,0 +1,299 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"github.com/google/uuid"
+	"github.com/tomanta/chirpy/internal/database"
+	"time"
+)
+
+// The export carries the user's own data in full, including bodies of
+// chirps hidden by a pending account deletion, so it has its own types
+// rather than reusing the public API views.
+type exportedChirp struct {
+	ID        uuid.UUID  `json:"id"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+	Body      string     `json:"body"`
+	InReplyTo *uuid.UUID `json:"in_reply_to,omitempty"`
+	RechirpOf *uuid.UUID `json:"rechirp_of,omitempty"`
+	QuoteOf   *uuid.UUID `json:"quote_of,omitempty"`
+	EditedAt  *time.Time `json:"edited_at,omitempty"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+}
+
+type exportedRevision struct {
+	ID         uuid.UUID `json:"id"`
+	ChirpID    uuid.UUID `json:"chirp_id"`
+	Body       string    `json:"body"`
+	CreatedAt  time.Time `json:"created_at"`
+	ReplacedAt time.Time `json:"replaced_at"`
+}
+
+type exportedLike struct {
+	ChirpID uuid.UUID `json:"chirp_id"`
+	LikedAt time.Time `json:"liked_at"`
+}
+
+type exportedSession struct {
+	CreatedAt  time.Time `json:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+	UserAgent  string    `json:"user_agent"`
+	IP         string    `json:"ip"`
+}
+
+type exportedMention struct {
+	ChirpID     uuid.UUID  `json:"chirp_id"`
+	MentionedAt time.Time  `json:"mentioned_at"`
+	ReadAt      *time.Time `json:"read_at,omitempty"`
+}
+
+type exportedAuditEvent struct {
+	CreatedAt time.Time  `json:"created_at"`
+	Event     string     `json:"event"`
+	SessionID *uuid.UUID `json:"session_id,omitempty"`
+	IP        string     `json:"ip"`
+	UserAgent string     `json:"user_agent"`
+}
+
+// exportedToken is a personal access token's metadata. The digest is left
+// out, like the password hash.
+type exportedToken struct {
+	ID         uuid.UUID  `json:"id"`
+	Name       string     `json:"name"`
+	Scopes     []string   `json:"scopes"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+}
+
+// exportedTwoFactor is the 2FA enrollment without its secret or the
+// recovery code digests.
+type exportedTwoFactor struct {
+	CreatedAt     time.Time              `json:"created_at"`
+	ConfirmedAt   *time.Time             `json:"confirmed_at,omitempty"`
+	RecoveryCodes []exportedRecoveryCode `json:"recovery_codes"`
+}
+
+type exportedRecoveryCode struct {
+	CreatedAt time.Time  `json:"created_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+}
+
+func nullTimePtr(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	return &t.Time
+}
+
+// buildExportArchive gathers everything held on a user into a zip of JSON
+// files.
+func buildExportArchive(ctx context.Context, q *database.Queries, userID uuid.UUID) ([]byte, error) {
+	user, err := q.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	dbChirps, err := q.ExportUserChirps(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	chirps := []exportedChirp{}
+	for _, c := range dbChirps {
+		chirp := exportedChirp{
+			ID:        c.ID,
+			CreatedAt: c.CreatedAt,
+			UpdatedAt: c.UpdatedAt,
+			Body:      c.Body,
+		}
+		if c.InReplyTo.Valid {
+			chirp.InReplyTo = &c.InReplyTo.UUID
+		}
+		if c.RechirpOf.Valid {
+			chirp.RechirpOf = &c.RechirpOf.UUID
+		}
+		if c.QuoteOf.Valid {
+			chirp.QuoteOf = &c.QuoteOf.UUID
+		}
+		if c.EditedAt.Valid {
+			chirp.EditedAt = &c.EditedAt.Time
+		}
+		if c.DeletedAt.Valid {
+			chirp.DeletedAt = &c.DeletedAt.Time
+		}
+		chirps = append(chirps, chirp)
+	}
+
+	dbRevisions, err := q.ExportUserChirpRevisions(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	revisions := []exportedRevision{}
+	for _, r := range dbRevisions {
+		revisions = append(revisions, exportedRevision{
+			ID:         r.ID,
+			ChirpID:    r.ChirpID,
+			Body:       r.Body,
+			CreatedAt:  r.CreatedAt,
+			ReplacedAt: r.ReplacedAt,
+		})
+	}
+
+	dbLikes, err := q.ExportUserLikes(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	likes := []exportedLike{}
+	for _, l := range dbLikes {
+		likes = append(likes, exportedLike{ChirpID: l.ChirpID, LikedAt: l.CreatedAt})
+	}
+
+	dbFollowing, err := q.ExportUserFollowing(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	following := []FollowedUser{}
+	for _, f := range dbFollowing {
+		following = append(following, FollowedUser{ID: f.FolloweeID, FollowedAt: f.CreatedAt})
+	}
+
+	dbFollowers, err := q.ExportUserFollowers(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	followers := []FollowedUser{}
+	for _, f := range dbFollowers {
+		followers = append(followers, FollowedUser{ID: f.FollowerID, FollowedAt: f.CreatedAt})
+	}
+
+	dbSessions, err := q.ExportUserSessions(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	sessions := []exportedSession{}
+	for _, s := range dbSessions {
+		sessions = append(sessions, exportedSession{
+			CreatedAt:  s.CreatedAt,
+			LastUsedAt: s.LastUsedAt,
+			UserAgent:  s.UserAgent,
+			IP:         s.Ip,
+		})
+	}
+
+	dbMentions, err := q.ExportUserMentions(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	mentions := []exportedMention{}
+	for _, m := range dbMentions {
+		mentions = append(mentions, exportedMention{
+			ChirpID:     m.ChirpID,
+			MentionedAt: m.CreatedAt,
+			ReadAt:      nullTimePtr(m.ReadAt),
+		})
+	}
+
+	dbEvents, err := q.ExportUserAuditEvents(ctx, uuid.NullUUID{UUID: userID, Valid: true})
+	if err != nil {
+		return nil, err
+	}
+	events := []exportedAuditEvent{}
+	for _, e := range dbEvents {
+		event := exportedAuditEvent{
+			CreatedAt: e.CreatedAt,
+			Event:     e.Event,
+			IP:        e.Ip,
+			UserAgent: e.UserAgent,
+		}
+		if e.SessionID.Valid {
+			event.SessionID = &e.SessionID.UUID
+		}
+		events = append(events, event)
+	}
+
+	dbTokens, err := q.ExportUserPersonalAccessTokens(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	tokens := []exportedToken{}
+	for _, t := range dbTokens {
+		tokens = append(tokens, exportedToken{
+			ID:         t.ID,
+			Name:       t.Name,
+			Scopes:     t.Scopes,
+			CreatedAt:  t.CreatedAt,
+			LastUsedAt: nullTimePtr(t.LastUsedAt),
+			ExpiresAt:  nullTimePtr(t.ExpiresAt),
+			RevokedAt:  nullTimePtr(t.RevokedAt),
+		})
+	}
+
+	// Without 2FA the file holds null.
+	var twoFactor *exportedTwoFactor
+	dbTOTP, err := q.ExportUserTOTP(ctx, userID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+	if err == nil {
+		dbCodes, err := q.ExportUserRecoveryCodes(ctx, userID)
+		if err != nil {
+			return nil, err
+		}
+		twoFactor = &exportedTwoFactor{
+			CreatedAt:     dbTOTP.CreatedAt,
+			ConfirmedAt:   nullTimePtr(dbTOTP.ConfirmedAt),
+			RecoveryCodes: []exportedRecoveryCode{},
+		}
+		for _, c := range dbCodes {
+			twoFactor.RecoveryCodes = append(twoFactor.RecoveryCodes, exportedRecoveryCode{
+				CreatedAt: c.CreatedAt,
+				UsedAt:    nullTimePtr(c.UsedAt),
+			})
+		}
+	}
+
+	files := []struct {
+		name string
+		data any
+	}{
+		{"profile.json", userFromDB(user)},
+		{"chirps.json", chirps},
+		{"revisions.json", revisions},
+		{"likes.json", likes},
+		{"following.json", following},
+		{"followers.json", followers},
+		{"sessions.json", sessions},
+		{"mentions.json", mentions},
+		{"audit_events.json", events},
+		{"tokens.json", tokens},
+		{"two_factor.json", twoFactor},
+	}
+
+	buf := bytes.Buffer{}
+	archive := zip.NewWriter(&buf)
+	for _, f := range files {
+		w, err := archive.Create(f.name)
+		if err != nil {
+			return nil, err
+		}
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(f.data)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = archive.Close()
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,12 @@
+package main
+
+import (
+	"net/http"
+)
+
+// handlerJWKS publishes the public signing keys so other services can
+// verify our access tokens without holding a secret.
+func (cfg *apiConfig) handlerJWKS(writer http.ResponseWriter, request *http.Request) {
+	writer.Header().Set("Cache-Control", "public, max-age=300")
+	respondWithJSON(writer, http.StatusOK, cfg.jwtKeys.JWKS())
+}
@@ -2,22 +2,35 @@ package main
 
 import (
 	"context"
+	"database/sql"
 	"encoding/json"
+	"errors"
+	"github.com/google/uuid"
 	"github.com/tomanta/chirpy/internal/auth"
 	"github.com/tomanta/chirpy/internal/database"
+	"log"
 	"net/http"
+	"slices"
 	"time"
 )
 
+const refreshTokenTTL = 60 * 24 * time.Hour
+
+// dummyPasswordHash is checked against when the email has no account, so
+// that failure takes as long as a wrong password does. It's a bcrypt hash
+// at the default cost of a password nobody uses.
+const dummyPasswordHash = "$2a$10$T5VvetXxg4XRxcQW0TOIDufY/dlypfTTOf2FVY.471epU6TNpMqIC"
+
 func (cfg *apiConfig) handlerUserLogin(writer http.ResponseWriter, request *http.Request) {
 	type parameters struct {
 		Email    string `json:"email"`
 		Password string `json:"password"`
+		// Scopes lets a client ask for less than a full login.
+		Scopes []string `json:"scopes"`
 	}
-	type response struct {
-		User
-		Token        string `json:"token"`
-		RefreshToken string `json:"refresh_token"`
+	type challengeResponse struct {
+		TwoFactorRequired bool   `json:"two_factor_required"`
+		ChallengeToken    string `json:"challenge_token"`
 	}
 
 	decoder := json.NewDecoder(request.Body)
@@ -29,23 +42,91 @@ func (cfg *apiConfig) handlerUserLogin(writer http.ResponseWriter, request *http
 		return
 	}
 
+	accountKey := emailLoginKey(params.Email)
+	if !cfg.checkLoginLock(writer, request, accountKey) {
+		return
+	}
+
+	// An unknown email and a wrong password get the same answer in the
+	// same time, so logging in doesn't reveal who has an account.
 	user, err := cfg.dbQueries.GetUserByEmail(context.Background(), params.Email)
 	if err != nil {
-		respondWithError(writer, http.StatusUnauthorized, "ncorrect email or password", err)
+		auth.CheckPasswordHash(params.Password, dummyPasswordHash)
+		cfg.loginFailed(request, accountKey, uuid.NullUUID{})
+		respondWithError(writer, http.StatusUnauthorized, "incorrect email or password", err)
 		return
 	}
 
 	err = auth.CheckPasswordHash(params.Password, user.HashedPassword)
 	if err != nil {
+		cfg.loginFailed(request, accountKey, uuid.NullUUID{UUID: user.ID, Valid: true})
 		respondWithError(writer, http.StatusUnauthorized, "incorrect email or password", err)
 		return
 	}
 
-	accessToken, err := auth.MakeJWT(user.ID, cfg.jwtSecret, time.Hour)
+	scopes, err := loginScopes(user, params.Scopes)
 	if err != nil {
-		respondWithError(writer, http.StatusInternalServerError, "Couldn't create JWT token", err)
+		respondWithError(writer, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+
+	// With 2FA on, the password only earns a challenge to redeem at
+	// /api/login/2fa.
+	twoFactor, err := cfg.dbQueries.GetUserTOTP(context.Background(), user.ID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		respondWithError(writer, http.StatusInternalServerError, "Couldn't check two-factor authentication", err)
 		return
 	}
+	if err == nil && twoFactor.ConfirmedAt.Valid {
+		challenge, err := auth.MakeTwoFactorChallenge(user.ID, cfg.jwtKeys, twoFactorChallengeTTL)
+		if err != nil {
+			respondWithError(writer, http.StatusInternalServerError, "Couldn't create JWT token", err)
+			return
+		}
+
+		respondWithJSON(writer, http.StatusOK, challengeResponse{
+			TwoFactorRequired: true,
+			ChallengeToken:    challenge,
+		})
+		return
+	}
+
+	cfg.respondWithLogin(writer, request, user, scopes)
+}
+
+// loginScopes works out what a login asking for requested gets. Admins may
+// ask for the admin scope too, but only get it when they do.
+func loginScopes(user database.User, requested []string) ([]auth.Scope, error) {
+	granted := auth.DefaultScopes
+	if user.IsAdmin {
+		granted = append(slices.Clone(granted), auth.ScopeAdmin)
+	}
+	if len(requested) == 0 {
+		return slices.Clone(auth.DefaultScopes), nil
+	}
+	return auth.ReduceScopes(granted, requested)
+}
+
+// respondWithLogin starts a new session for user on the requesting device
+// and issues its access and refresh token pair, restoring the account if it
+// was pending deletion.
+func (cfg *apiConfig) respondWithLogin(writer http.ResponseWriter, request *http.Request, user database.User, scopes []auth.Scope) {
+	type response struct {
+		User
+		Token        string `json:"token"`
+		RefreshToken string `json:"refresh_token"`
+		Scope        string `json:"scope"`
+	}
+
+	// Logging in during the deletion grace period keeps the account.
+	if user.DeletedAt.Valid {
+		err := cfg.restoreAccount(context.Background(), user.ID)
+		if err != nil {
+			respondWithError(writer, http.StatusInternalServerError, "Couldn't restore user", err)
+			return
+		}
+		user.DeletedAt = sql.NullTime{}
+	}
 
 	refreshToken, err := auth.MakeRefreshToken()
 	if err != nil {
@@ -53,67 +134,211 @@ func (cfg *apiConfig) handlerUserLogin(writer http.ResponseWriter, request *http
 		return
 	}
 
-	_, err = cfg.dbQueries.CreateRefreshToken(context.Background(), database.CreateRefreshTokenParams{
-		Token:     refreshToken,
+	tx, err := cfg.db.BeginTx(context.Background(), nil)
+	if err != nil {
+		respondWithError(writer, http.StatusInternalServerError, "Couldn't create session", err)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.dbQueries.WithTx(tx)
+
+	session, err := qtx.CreateSession(context.Background(), database.CreateSessionParams{
+		UserID:    user.ID,
+		UserAgent: truncate(request.UserAgent(), maxUserAgentLength),
+		Ip:        clientIP(request),
+		Scopes:    auth.ScopeNames(scopes),
+	})
+	if err != nil {
+		respondWithError(writer, http.StatusInternalServerError, "Couldn't create session", err)
+		return
+	}
+
+	// Only the digest is stored, and lookups go by digest, so neither a
+	// copy of the table nor lookup timing gives away a usable token.
+	_, err = qtx.CreateRefreshToken(context.Background(), database.CreateRefreshTokenParams{
+		TokenHash: auth.HashToken(refreshToken),
 		UserID:    user.ID,
-		ExpiresAt: time.Now().UTC().Add(time.Hour * 24 * 60),
+		SessionID: session.ID,
+		ExpiresAt: time.Now().UTC().Add(refreshTokenTTL),
 	})
 	if err != nil {
 		respondWithError(writer, http.StatusInternalServerError, "Couldn't save refresh token", err)
 		return
 	}
 
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(writer, http.StatusInternalServerError, "Couldn't create session", err)
+		return
+	}
+
+	cfg.clearLoginFailures(context.Background(), user)
+
+	accessToken, err := auth.MakeAccessToken(auth.AccessClaims{
+		UserID:    user.ID,
+		SessionID: session.ID,
+		Scopes:    scopes,
+	}, cfg.jwtKeys, time.Hour)
+	if err != nil {
+		respondWithError(writer, http.StatusInternalServerError, "Couldn't create JWT token", err)
+		return
+	}
+
 	payload := response{
-		User: User{
-			Id:          user.ID,
-			CreatedAt:   user.CreatedAt,
-			UpdatedAt:   user.UpdatedAt,
-			Email:       user.Email,
-			IsChirpyRed: user.IsChirpyRed,
-		},
+		User:         userFromDB(user),
 		Token:        accessToken,
 		RefreshToken: refreshToken,
+		Scope:        auth.FormatScopes(scopes),
 	}
 
 	respondWithJSON(writer, http.StatusOK, payload)
 
 }
 
+// handlerRefresh swaps a refresh token for a new access token and a new
+// refresh token in the same session. Each refresh token works only once, so
+// seeing a rotated one again means it has leaked.
 func (cfg *apiConfig) handlerRefresh(writer http.ResponseWriter, request *http.Request) {
 	refreshToken, err := auth.GetBearerToken(request.Header)
 	if err != nil {
 		respondWithError(writer, http.StatusBadRequest, "Couldn't find token", err)
+		return
 	}
+	tokenHash := auth.HashToken(refreshToken)
 
-	user, err := cfg.dbQueries.GetUserFromRefreshToken(context.Background(), refreshToken)
+	user, err := cfg.dbQueries.GetUserFromRefreshToken(context.Background(), tokenHash)
 	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			cfg.revokeReusedRefreshToken(context.Background(), request, tokenHash)
+		}
 		respondWithError(writer, http.StatusUnauthorized, "Couldn't get user from refresh token", err)
 		return
 	}
 
-	accessToken, err := auth.MakeJWT(user.UserID, cfg.jwtSecret, time.Hour)
+	newRefreshToken, err := auth.MakeRefreshToken()
+	if err != nil {
+		respondWithError(writer, http.StatusInternalServerError, "Couldn't create refresh token", err)
+		return
+	}
+
+	tx, err := cfg.db.BeginTx(context.Background(), nil)
+	if err != nil {
+		respondWithError(writer, http.StatusInternalServerError, "Couldn't refresh session", err)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.dbQueries.WithTx(tx)
+
+	rotated, err := qtx.RotateRefreshToken(context.Background(), tokenHash)
+	if err != nil {
+		respondWithError(writer, http.StatusInternalServerError, "Couldn't refresh session", err)
+		return
+	}
+	if rotated == 0 {
+		// A concurrent request spent the token between the lookup and here.
+		tx.Rollback()
+		cfg.revokeReusedRefreshToken(context.Background(), request, tokenHash)
+		respondWithError(writer, http.StatusUnauthorized, "Couldn't get user from refresh token", nil)
+		return
+	}
+
+	_, err = qtx.CreateRefreshToken(context.Background(), database.CreateRefreshTokenParams{
+		TokenHash: auth.HashToken(newRefreshToken),
+		UserID:    user.UserID,
+		SessionID: user.SessionID,
+		ExpiresAt: time.Now().UTC().Add(refreshTokenTTL),
+	})
+	if err != nil {
+		respondWithError(writer, http.StatusInternalServerError, "Couldn't save refresh token", err)
+		return
+	}
+
+	err = qtx.TouchSession(context.Background(), user.SessionID)
+	if err != nil {
+		respondWithError(writer, http.StatusInternalServerError, "Couldn't refresh session", err)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(writer, http.StatusInternalServerError, "Couldn't refresh session", err)
+		return
+	}
+
+	scopes := auth.ScopesFromNames(user.Scopes)
+	accessToken, err := auth.MakeAccessToken(auth.AccessClaims{
+		UserID:    user.UserID,
+		SessionID: user.SessionID,
+		Scopes:    scopes,
+	}, cfg.jwtKeys, time.Hour)
 	if err != nil {
 		respondWithError(writer, http.StatusUnauthorized, "Couldn't validate token", err)
 		return
 	}
 
 	type response struct {
-		Token string `json:"token"`
+		Token        string `json:"token"`
+		RefreshToken string `json:"refresh_token"`
+		Scope        string `json:"scope"`
 	}
 	respondWithJSON(writer, http.StatusOK, response{
-		Token: accessToken,
+		Token:        accessToken,
+		RefreshToken: newRefreshToken,
+		Scope:        auth.FormatScopes(scopes),
 	})
 }
 
+// revokeReusedRefreshToken revokes the whole session a refresh token
+// belonged to if that token has already been rotated. Whoever holds the
+// session's live refresh token could be the attacker or the user, so
+// neither is trusted.
+func (cfg *apiConfig) revokeReusedRefreshToken(ctx context.Context, request *http.Request, tokenHash string) {
+	rotated, err := cfg.dbQueries.GetRotatedRefreshToken(ctx, tokenHash)
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			log.Printf("Couldn't check refresh token reuse: %s", err)
+		}
+		return
+	}
+
+	tx, err := cfg.db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Printf("Couldn't revoke session %s: %s", rotated.SessionID, err)
+		return
+	}
+	defer tx.Rollback()
+
+	_, err = revokeSession(ctx, cfg.dbQueries.WithTx(tx), rotated.UserID, rotated.SessionID)
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		log.Printf("Couldn't revoke session %s: %s", rotated.SessionID, err)
+	}
+
+	cfg.recordAuditEvent(ctx, request, auditRefreshTokenReuse,
+		uuid.NullUUID{UUID: rotated.UserID, Valid: true},
+		uuid.NullUUID{UUID: rotated.SessionID, Valid: true})
+}
+
 func (cfg *apiConfig) handlerRevoke(writer http.ResponseWriter, request *http.Request) {
 	refreshToken, err := auth.GetBearerToken(request.Header)
 	if err != nil {
 		respondWithError(writer, http.StatusBadRequest, "Couldn't find token", err)
+		return
 	}
+	tokenHash := auth.HashToken(refreshToken)
 
-	err = cfg.dbQueries.RevokeRefreshToken(context.Background(), refreshToken)
+	err = cfg.dbQueries.RevokeRefreshTokenSession(context.Background(), tokenHash)
 	if err != nil {
 		respondWithError(writer, http.StatusInternalServerError, "Could not revoke session", err)
+		return
+	}
+
+	err = cfg.dbQueries.RevokeRefreshToken(context.Background(), tokenHash)
+	if err != nil {
+		respondWithError(writer, http.StatusInternalServerError, "Could not revoke session", err)
+		return
 	}
 
 	writer.WriteHeader(http.StatusNoContent)
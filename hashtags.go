@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"github.com/tomanta/chirpy/internal/database"
+	"regexp"
+	"strings"
+)
+
+const maxHashtagLength = 64
+
+// A hashtag is a # followed by letters, digits or underscores, and must not
+// be glued to the end of another word ("abc#def" is not a tag).
+var (
+	hashtagPattern = regexp.MustCompile(`(?:^|[^\p{L}\p{N}_#])#([\p{L}\p{N}_]+)`)
+	hashtagChars   = regexp.MustCompile(`^[\p{L}\p{N}_]+$`)
+)
+
+// extractHashtags returns the distinct, lower-cased tags in body in the order
+// they first appear. Purely numeric tags such as "#1" are ignored.
+func extractHashtags(body string) []string {
+	tags := []string{}
+	seen := map[string]struct{}{}
+	for _, match := range hashtagPattern.FindAllStringSubmatch(body, -1) {
+		tag := normalizeHashtag(match[1])
+		if tag == "" {
+			continue
+		}
+		if _, ok := seen[tag]; ok {
+			continue
+		}
+		seen[tag] = struct{}{}
+		tags = append(tags, tag)
+	}
+	return tags
+}
+
+// normalizeHashtag lower-cases a tag and strips a leading #. It returns ""
+// for anything that is not a valid tag.
+func normalizeHashtag(tag string) string {
+	tag = strings.ToLower(strings.TrimPrefix(tag, "#"))
+	if tag == "" || len(tag) > maxHashtagLength {
+		return ""
+	}
+	if !hashtagChars.MatchString(tag) || strings.Trim(tag, "0123456789") == "" {
+		return ""
+	}
+	return tag
+}
+
+// indexHashtags records the tags in a chirp's current body. Callers pass the
+// transaction the chirp itself was written in so the index can't drift from
+// the chirps table.
+func indexHashtags(ctx context.Context, q *database.Queries, chirp database.Chirp) error {
+	tags := extractHashtags(chirp.Body)
+	if len(tags) == 0 {
+		return nil
+	}
+	return q.TagChirp(ctx, database.TagChirpParams{
+		Tags:      tags,
+		ChirpID:   chirp.ID,
+		CreatedAt: chirp.CreatedAt,
+	})
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestExtractHashtags(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want []string
+	}{
+		{
+			name: "No tags",
+			body: "just a chirp",
+			want: []string{},
+		},
+		{
+			name: "Case folding",
+			body: "#Go #GOLANG",
+			want: []string{"go", "golang"},
+		},
+		{
+			name: "Duplicates keep first position",
+			body: "#go then #rust then #Go",
+			want: []string{"go", "rust"},
+		},
+		{
+			name: "All-digit tags are ignored",
+			body: "#1 #2024 #1a",
+			want: []string{"1a"},
+		},
+		{
+			name: "Double hash",
+			body: "##x",
+			want: []string{},
+		},
+		{
+			name: "Glued to a word",
+			body: "abc#def",
+			want: []string{},
+		},
+		{
+			name: "Next to punctuation",
+			body: "(#tag), #end. \"#quoted\" #q!",
+			want: []string{"tag", "end", "quoted", "q"},
+		},
+		{
+			name: "Unicode letters",
+			body: "#Café",
+			want: []string{"café"},
+		},
+		{
+			name: "Too long",
+			body: "#" + strings.Repeat("a", maxHashtagLength+1),
+			want: []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := extractHashtags(tt.body); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("extractHashtags(%q) = %v, want %v", tt.body, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalizeHashtag(t *testing.T) {
+	tests := []struct {
+		name string
+		tag  string
+		want string
+	}{
+		{name: "Leading hash", tag: "#Go", want: "go"},
+		{name: "No hash", tag: "Go", want: "go"},
+		{name: "Empty", tag: "", want: ""},
+		{name: "Only a hash", tag: "#", want: ""},
+		{name: "Double hash", tag: "##x", want: ""},
+		{name: "All digits", tag: "#123", want: ""},
+		{name: "Digits and letters", tag: "#web3", want: "web3"},
+		{name: "Punctuation", tag: "#a-b", want: ""},
+		{name: "Longest allowed", tag: strings.Repeat("a", maxHashtagLength), want: strings.Repeat("a", maxHashtagLength)},
+		{name: "Too long", tag: strings.Repeat("a", maxHashtagLength+1), want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := normalizeHashtag(tt.tag); got != tt.want {
+				t.Errorf("normalizeHashtag(%q) = %q, want %q", tt.tag, got, tt.want)
+			}
+		})
+	}
+}
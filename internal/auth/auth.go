@@ -11,12 +11,17 @@ import (
 	"strings"
 	"crypto/rand"
 	"encoding/hex"
+	"crypto/sha256"
 )
 
 type TokenType string
 
 const (
 	TokenTypeAccess TokenType = "chirpy-access"
+	// TokenTypeTwoFactor marks the short-lived challenge issued after a
+	// correct password when the account has 2FA on. It can't be used as an
+	// access token.
+	TokenTypeTwoFactor TokenType = "chirpy-2fa"
 )
 
 func HashPassword(password string) (string, error) {
@@ -31,47 +36,106 @@ func CheckPasswordHash(password, hash string) error {
 	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
 }
 
+// tokenClaims carries the session an access token was issued for, so
+// revoking the session can revoke the token too, and what it may be used
+// for.
+type tokenClaims struct {
+	jwt.RegisteredClaims
+	SessionID string `json:"sid,omitempty"`
+	Scope     string `json:"scope,omitempty"`
+}
+
 func MakeJWT(userID uuid.UUID, tokenSecret string, expiresIn time.Duration) (string, error) {
-	signingKey := []byte(tokenSecret)
-	
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims {
-		Issuer: string(TokenTypeAccess),
-		IssuedAt: jwt.NewNumericDate(time.Now().UTC()),
-		ExpiresAt: jwt.NewNumericDate(time.Now().UTC().Add(expiresIn)),
-		Subject: userID.String(),
-
-	})
-	return token.SignedString(signingKey)
+	return makeJWT(AccessClaims{UserID: userID}, NewHMACKeyring(tokenSecret), expiresIn, TokenTypeAccess)
+}
+
+// MakeAccessToken issues an access token tied to a login session.
+func MakeAccessToken(claims AccessClaims, keys *Keyring, expiresIn time.Duration) (string, error) {
+	return makeJWT(claims, keys, expiresIn, TokenTypeAccess)
+}
+
+func MakeTwoFactorChallenge(userID uuid.UUID, keys *Keyring, expiresIn time.Duration) (string, error) {
+	return makeJWT(AccessClaims{UserID: userID}, keys, expiresIn, TokenTypeTwoFactor)
+}
+
+func makeJWT(access AccessClaims, keys *Keyring, expiresIn time.Duration, tokenType TokenType) (string, error) {
+	claims := tokenClaims{
+		RegisteredClaims: jwt.RegisteredClaims {
+			Issuer: string(tokenType),
+			IssuedAt: jwt.NewNumericDate(time.Now().UTC()),
+			ExpiresAt: jwt.NewNumericDate(time.Now().UTC().Add(expiresIn)),
+			Subject: access.UserID.String(),
+		},
+		Scope: FormatScopes(access.Scopes),
+	}
+	if access.SessionID != uuid.Nil {
+		claims.SessionID = access.SessionID.String()
+	}
+
+	return keys.sign(claims)
 }
 
 func ValidateJWT(tokenString, tokenSecret string) (uuid.UUID, error) {
-	claimsStruct := jwt.RegisteredClaims{}
+	claims, err := validateJWT(tokenString, NewHMACKeyring(tokenSecret), TokenTypeAccess)
+	return claims.UserID, err
+}
+
+// ValidateAccessToken validates an access token and returns the user, the
+// session it was issued for and its scopes. Tokens without a session are
+// rejected.
+func ValidateAccessToken(tokenString string, keys *Keyring) (AccessClaims, error) {
+	claims, err := validateJWT(tokenString, keys, TokenTypeAccess)
+	if err != nil {
+		return AccessClaims{}, err
+	}
+	if claims.SessionID == uuid.Nil {
+		return AccessClaims{}, errors.New("token has no session")
+	}
+	return claims, nil
+}
+
+func ValidateTwoFactorChallenge(tokenString string, keys *Keyring) (uuid.UUID, error) {
+	claims, err := validateJWT(tokenString, keys, TokenTypeTwoFactor)
+	return claims.UserID, err
+}
+
+func validateJWT(tokenString string, keys *Keyring, tokenType TokenType) (AccessClaims, error) {
+	claimsStruct := tokenClaims{}
 	
-	token, err := jwt.ParseWithClaims(tokenString, &claimsStruct, func(token *jwt.Token) (interface{}, error) {
-		return []byte(tokenSecret), nil
-	})
+	token, err := jwt.ParseWithClaims(tokenString, &claimsStruct, keys.keyFunc)
 	if err != nil {
-		return uuid.Nil, err
+		return AccessClaims{}, err
 	}
 
 	userIDString, err := token.Claims.GetSubject()
 	if err != nil {
-		return uuid.Nil, err
+		return AccessClaims{}, err
 	}
 
 	issuer, err := token.Claims.GetIssuer()
 	if err != nil {
-		return uuid.Nil, err
+		return AccessClaims{}, err
 	}
-	if issuer != string(TokenTypeAccess) {
-		return uuid.Nil, errors.New("invalid issuer")
+	if issuer != string(tokenType) {
+		return AccessClaims{}, errors.New("invalid issuer")
 	}
 
 	id, err := uuid.Parse(userIDString)
 	if err != nil {
-		return uuid.Nil, fmt.Errorf("invaid user ID: %w", err)
+		return AccessClaims{}, fmt.Errorf("invaid user ID: %w", err)
+	}
+
+	claims := AccessClaims{
+		UserID: id,
+		Scopes: ParseScopes(claimsStruct.Scope),
 	}
-	return id, nil
+	if claimsStruct.SessionID != "" {
+		claims.SessionID, err = uuid.Parse(claimsStruct.SessionID)
+		if err != nil {
+			return AccessClaims{}, fmt.Errorf("invalid session ID: %w", err)
+		}
+	}
+	return claims, nil
 
 }
 
@@ -106,4 +170,11 @@ func GetAPIKey(headers http.Header) (string, error) {
 		return "", errors.New("malformed authorization header")
 	}
 	return splitAuth[1], nil
+}
+
+// HashToken returns the SHA-256 hex digest of an opaque token so it can be
+// stored and looked up without keeping the token itself.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
 }
\ No newline at end of file
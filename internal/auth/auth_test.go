@@ -5,6 +5,7 @@ import (
 	"time"
 	"github.com/google/uuid"
 	"net/http"
+	"reflect"
 )
 
 func TestCheckPasswordHash(t *testing.T) {
@@ -110,6 +111,62 @@ func TestValidateJWT(t *testing.T) {
 	}
 }
 
+func TestValidateAccessToken(t *testing.T) {
+	keys := NewHMACKeyring("secret")
+	want := AccessClaims{
+		UserID:    uuid.New(),
+		SessionID: uuid.New(),
+		Scopes:    []Scope{ScopeChirpsWrite, ScopeAccount},
+	}
+	sessionToken, _ := MakeAccessToken(want, keys, time.Hour)
+	plainToken, _ := MakeJWT(want.UserID, "secret", time.Hour)
+
+	tests := []struct {
+		name        string
+		tokenString string
+		tokenSecret string
+		want        AccessClaims
+		wantErr     bool
+	}{
+		{
+			name:        "Valid token",
+			tokenString: sessionToken,
+			tokenSecret: "secret",
+			want:        want,
+		},
+		{
+			name:        "Token without session",
+			tokenString: plainToken,
+			tokenSecret: "secret",
+			wantErr:     true,
+		},
+		{
+			name:        "Wrong secret",
+			tokenString: sessionToken,
+			tokenSecret: "wrong_secret",
+			wantErr:     true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ValidateAccessToken(tt.tokenString, NewHMACKeyring(tt.tokenSecret))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateAccessToken() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ValidateAccessToken() got = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	// Session tokens are still ordinary access tokens.
+	if gotUserID, err := ValidateJWT(sessionToken, "secret"); err != nil || gotUserID != want.UserID {
+		t.Errorf("ValidateJWT() = %v, %v; want %v", gotUserID, err, want.UserID)
+	}
+}
+
 func TestGetBearerToken(t *testing.T) {
 	header1 := http.Header{}
 	header1.Set("Authorization", "Bearer valid_token")
@@ -157,4 +214,31 @@ func TestGetBearerToken(t *testing.T) {
 			}
 		})
 	}
+}
+
+func TestHashToken(t *testing.T) {
+	tests := []struct {
+		name  string
+		token string
+		want  string
+	}{
+		{
+			name:  "Known digest",
+			token: "abc",
+			want:  "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad",
+		},
+		{
+			name:  "Empty token",
+			token: "",
+			want:  "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := HashToken(tt.token); got != tt.want {
+				t.Errorf("HashToken() = %v, want %v", got, tt.want)
+			}
+		})
+	}
 }
\ No newline at end of file
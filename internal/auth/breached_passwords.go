@@ -0,0 +1,55 @@
+package auth
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"io/fs"
+	"strings"
+)
+
+// breachedPrefixLength is how much of the SHA-1 names a range file.
+const breachedPrefixLength = 5
+
+// BreachedPasswords is a local copy of a breached-password corpus in the
+// k-anonymity layout the Pwned Passwords range API uses: one file per
+// five-character SHA-1 prefix, named like "5BAA6.txt", each line holding
+// the other 35 hex characters and a count, as in
+// "1E4C9B93F3F0682250B6CF8331B7EE68FD8:3861493". Only the one file for a
+// password's prefix is read to check it.
+type BreachedPasswords struct {
+	fsys fs.FS
+}
+
+func NewBreachedPasswords(fsys fs.FS) *BreachedPasswords {
+	return &BreachedPasswords{fsys: fsys}
+}
+
+// Contains reports whether password is in the corpus. A missing range
+// file means no password with that prefix has been seen.
+func (b *BreachedPasswords) Contains(password string) (bool, error) {
+	sum := sha1.Sum([]byte(password))
+	digest := strings.ToUpper(hex.EncodeToString(sum[:]))
+	prefix, suffix := digest[:breachedPrefixLength], digest[breachedPrefixLength:]
+
+	file, err := b.fsys.Open(prefix + ".txt")
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		lineSuffix, count, _ := strings.Cut(strings.TrimSpace(scanner.Text()), ":")
+		// Padding entries, added to hide the real size of a range, have
+		// a count of zero.
+		if strings.EqualFold(lineSuffix, suffix) && count != "0" {
+			return true, nil
+		}
+	}
+	return false, scanner.Err()
+}
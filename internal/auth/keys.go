@@ -0,0 +1,181 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"github.com/golang-jwt/jwt/v5"
+	"math/big"
+)
+
+const minRSAKeyBits = 2048
+
+// SigningKey is one version of a JWT signing key. Its ID goes in the kid
+// header of every token it signs.
+type SigningKey struct {
+	ID        string
+	method    jwt.SigningMethod
+	signKey   any
+	verifyKey any
+}
+
+func NewHMACKey(id string, secret []byte) SigningKey {
+	return SigningKey{
+		ID:        id,
+		method:    jwt.SigningMethodHS256,
+		signKey:   secret,
+		verifyKey: secret,
+	}
+}
+
+func NewRSAKey(id string, key *rsa.PrivateKey) (SigningKey, error) {
+	if key.N.BitLen() < minRSAKeyBits {
+		return SigningKey{}, fmt.Errorf("RSA key %q is %d bits, need at least %d", id, key.N.BitLen(), minRSAKeyBits)
+	}
+	return SigningKey{
+		ID:        id,
+		method:    jwt.SigningMethodRS256,
+		signKey:   key,
+		verifyKey: &key.PublicKey,
+	}, nil
+}
+
+func NewEd25519Key(id string, key ed25519.PrivateKey) SigningKey {
+	return SigningKey{
+		ID:        id,
+		method:    jwt.SigningMethodEdDSA,
+		signKey:   key,
+		verifyKey: key.Public(),
+	}
+}
+
+// ParsePrivateKeyPEM reads an RSA or Ed25519 private key in PKCS #8 PEM,
+// or an RSA key in PKCS #1 PEM.
+func ParsePrivateKeyPEM(id string, data []byte) (SigningKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return SigningKey{}, fmt.Errorf("key %q is not PEM encoded", id)
+	}
+
+	if block.Type == "RSA PRIVATE KEY" {
+		key, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+		if err != nil {
+			return SigningKey{}, fmt.Errorf("key %q: %w", id, err)
+		}
+		return NewRSAKey(id, key)
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return SigningKey{}, fmt.Errorf("key %q: %w", id, err)
+	}
+	switch key := key.(type) {
+	case *rsa.PrivateKey:
+		return NewRSAKey(id, key)
+	case ed25519.PrivateKey:
+		return NewEd25519Key(id, key), nil
+	default:
+		return SigningKey{}, fmt.Errorf("key %q has unsupported type %T", id, key)
+	}
+}
+
+// Keyring signs with its current key and verifies with any key it holds,
+// so a new key can be rolled out while tokens signed by the old ones are
+// still live.
+type Keyring struct {
+	current SigningKey
+	keys    []SigningKey
+	byID    map[string]SigningKey
+}
+
+func NewKeyring(current SigningKey, previous ...SigningKey) (*Keyring, error) {
+	keyring := &Keyring{
+		current: current,
+		byID:    map[string]SigningKey{},
+	}
+	for _, key := range append([]SigningKey{current}, previous...) {
+		if _, ok := keyring.byID[key.ID]; ok {
+			return nil, fmt.Errorf("duplicate key ID %q", key.ID)
+		}
+		keyring.keys = append(keyring.keys, key)
+		keyring.byID[key.ID] = key
+	}
+	return keyring, nil
+}
+
+// NewHMACKeyring holds a single HS256 secret with no key ID, which matches
+// tokens issued before keys were versioned.
+func NewHMACKeyring(secret string) *Keyring {
+	keyring, _ := NewKeyring(NewHMACKey("", []byte(secret)))
+	return keyring
+}
+
+func (k *Keyring) sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(k.current.method, claims)
+	if k.current.ID != "" {
+		token.Header["kid"] = k.current.ID
+	}
+	return token.SignedString(k.current.signKey)
+}
+
+// keyFunc picks the verification key by kid. The algorithm comes from the
+// key rather than the token, so a public key can't be passed off as an
+// HMAC secret.
+func (k *Keyring) keyFunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	key, ok := k.byID[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown key ID %q", kid)
+	}
+	if token.Method.Alg() != key.method.Alg() {
+		return nil, errors.New("unexpected signing method")
+	}
+	return key.verifyKey, nil
+}
+
+// JWK is the public half of a signing key in RFC 7517 form.
+type JWK struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	Curve     string `json:"crv,omitempty"`
+	N         string `json:"n,omitempty"`
+	E         string `json:"e,omitempty"`
+	X         string `json:"x,omitempty"`
+}
+
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS lists the public keys in the keyring, current key first. HMAC keys
+// are secret and never listed.
+func (k *Keyring) JWKS() JWKSet {
+	set := JWKSet{Keys: []JWK{}}
+	for _, key := range k.keys {
+		jwk := JWK{
+			KeyID:     key.ID,
+			Use:       "sig",
+			Algorithm: key.method.Alg(),
+		}
+		switch public := key.verifyKey.(type) {
+		case *rsa.PublicKey:
+			jwk.KeyType = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(public.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.KeyType = "OKP"
+			jwk.Curve = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(public)
+		default:
+			continue
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+	return set
+}
@@ -0,0 +1,299 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"testing"
+	"time"
+)
+
+func testRSAKey(t *testing.T, id string) SigningKey {
+	t.Helper()
+	private, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := NewRSAKey(id, private)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func testEd25519Key(t *testing.T, id string) SigningKey {
+	t.Helper()
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewEd25519Key(id, private)
+}
+
+func TestKeyringSignAndValidate(t *testing.T) {
+	userID := uuid.New()
+	sessionID := uuid.New()
+
+	tests := []struct {
+		name    string
+		key     SigningKey
+		wantAlg string
+	}{
+		{
+			name:    "HS256",
+			key:     NewHMACKey("hmac-1", []byte("secret")),
+			wantAlg: "HS256",
+		},
+		{
+			name:    "RS256",
+			key:     testRSAKey(t, "rsa-1"),
+			wantAlg: "RS256",
+		},
+		{
+			name:    "EdDSA",
+			key:     testEd25519Key(t, "ed-1"),
+			wantAlg: "EdDSA",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keys, err := NewKeyring(tt.key)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			token, err := MakeAccessToken(AccessClaims{UserID: userID, SessionID: sessionID}, keys, time.Hour)
+			if err != nil {
+				t.Fatalf("MakeAccessToken() error = %v", err)
+			}
+
+			parsed, _, err := jwt.NewParser().ParseUnverified(token, &tokenClaims{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if parsed.Method.Alg() != tt.wantAlg {
+				t.Errorf("alg = %v, want %v", parsed.Method.Alg(), tt.wantAlg)
+			}
+			if parsed.Header["kid"] != tt.key.ID {
+				t.Errorf("kid = %v, want %v", parsed.Header["kid"], tt.key.ID)
+			}
+
+			got, err := ValidateAccessToken(token, keys)
+			if err != nil {
+				t.Fatalf("ValidateAccessToken() error = %v", err)
+			}
+			if got.UserID != userID || got.SessionID != sessionID {
+				t.Errorf("ValidateAccessToken() = %v, %v; want %v, %v", got.UserID, got.SessionID, userID, sessionID)
+			}
+		})
+	}
+}
+
+func TestKeyringRotation(t *testing.T) {
+	userID := uuid.New()
+	sessionID := uuid.New()
+	oldKey := testEd25519Key(t, "2026-01")
+	newKey := testRSAKey(t, "2026-07")
+
+	oldKeys, _ := NewKeyring(oldKey)
+	rotatedKeys, _ := NewKeyring(newKey, oldKey)
+	newKeysOnly, _ := NewKeyring(newKey)
+
+	oldToken, _ := MakeAccessToken(AccessClaims{UserID: userID, SessionID: sessionID}, oldKeys, time.Hour)
+	newToken, _ := MakeAccessToken(AccessClaims{UserID: userID, SessionID: sessionID}, rotatedKeys, time.Hour)
+
+	tests := []struct {
+		name    string
+		token   string
+		keys    *Keyring
+		wantErr bool
+	}{
+		{
+			name:  "Old token during rotation",
+			token: oldToken,
+			keys:  rotatedKeys,
+		},
+		{
+			name:  "New token during rotation",
+			token: newToken,
+			keys:  rotatedKeys,
+		},
+		{
+			name:    "Old token after old key is retired",
+			token:   oldToken,
+			keys:    newKeysOnly,
+			wantErr: true,
+		},
+		{
+			name:    "New token against old keyring",
+			token:   newToken,
+			keys:    oldKeys,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ValidateAccessToken(tt.token, tt.keys)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateAccessToken() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestKeyringRejectsAlgorithmConfusion(t *testing.T) {
+	rsaKey := testRSAKey(t, "rsa-1")
+	keys, _ := NewKeyring(rsaKey)
+
+	// An attacker who knows the public key signs an HS256 token with it.
+	publicDER, err := x509.MarshalPKIXPublicKey(rsaKey.verifyKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims := tokenClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    string(TokenTypeAccess),
+			Subject:   uuid.NewString(),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		},
+		SessionID: uuid.NewString(),
+	}
+	forged := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	forged.Header["kid"] = rsaKey.ID
+	forgedToken, err := forged.SignedString(publicDER)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	unknown := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	unknown.Header["kid"] = "missing"
+	unknownToken, _ := unknown.SignedString([]byte("secret"))
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{
+			name:  "HS256 signed with the RSA public key",
+			token: forgedToken,
+		},
+		{
+			name:  "Unknown key ID",
+			token: unknownToken,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ValidateAccessToken(tt.token, keys); err == nil {
+				t.Errorf("ValidateAccessToken() accepted a forged token")
+			}
+		})
+	}
+}
+
+func TestParsePrivateKeyPEM(t *testing.T) {
+	rsaPrivate, _ := rsa.GenerateKey(rand.Reader, 2048)
+	smallRSAPrivate, _ := rsa.GenerateKey(rand.Reader, 1024)
+	_, edPrivate, _ := ed25519.GenerateKey(rand.Reader)
+
+	pkcs8 := func(key any) []byte {
+		der, err := x509.MarshalPKCS8PrivateKey(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	}
+
+	tests := []struct {
+		name    string
+		data    []byte
+		wantAlg string
+		wantErr bool
+	}{
+		{
+			name:    "PKCS #8 RSA",
+			data:    pkcs8(rsaPrivate),
+			wantAlg: "RS256",
+		},
+		{
+			name:    "PKCS #1 RSA",
+			data:    pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(rsaPrivate)}),
+			wantAlg: "RS256",
+		},
+		{
+			name:    "PKCS #8 Ed25519",
+			data:    pkcs8(edPrivate),
+			wantAlg: "EdDSA",
+		},
+		{
+			name:    "RSA key too small",
+			data:    pkcs8(smallRSAPrivate),
+			wantErr: true,
+		},
+		{
+			name:    "Not PEM",
+			data:    []byte("not a key"),
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, err := ParsePrivateKeyPEM("key-1", tt.data)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParsePrivateKeyPEM() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !tt.wantErr && key.method.Alg() != tt.wantAlg {
+				t.Errorf("ParsePrivateKeyPEM() alg = %v, want %v", key.method.Alg(), tt.wantAlg)
+			}
+		})
+	}
+}
+
+func TestJWKS(t *testing.T) {
+	rsaKey := testRSAKey(t, "rsa-1")
+	edKey := testEd25519Key(t, "ed-1")
+	keys, err := NewKeyring(edKey, rsaKey, NewHMACKey("", []byte("secret")))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	set := keys.JWKS()
+	if len(set.Keys) != 2 {
+		t.Fatalf("JWKS() has %d keys, want 2", len(set.Keys))
+	}
+
+	ed := set.Keys[0]
+	if ed.KeyID != "ed-1" || ed.KeyType != "OKP" || ed.Curve != "Ed25519" || ed.Algorithm != "EdDSA" {
+		t.Errorf("JWKS() Ed25519 key = %+v", ed)
+	}
+	x, err := base64.RawURLEncoding.DecodeString(ed.X)
+	if err != nil || !edKey.verifyKey.(ed25519.PublicKey).Equal(ed25519.PublicKey(x)) {
+		t.Errorf("JWKS() Ed25519 x doesn't match the public key")
+	}
+
+	rs := set.Keys[1]
+	if rs.KeyID != "rsa-1" || rs.KeyType != "RSA" || rs.Algorithm != "RS256" || rs.E != "AQAB" {
+		t.Errorf("JWKS() RSA key = %+v", rs)
+	}
+	n, err := base64.RawURLEncoding.DecodeString(rs.N)
+	if err != nil || string(n) != string(rsaKey.verifyKey.(*rsa.PublicKey).N.Bytes()) {
+		t.Errorf("JWKS() RSA n doesn't match the public key")
+	}
+}
+
+func TestNewKeyringDuplicateID(t *testing.T) {
+	_, err := NewKeyring(NewHMACKey("a", []byte("one")), NewHMACKey("a", []byte("two")))
+	if err == nil {
+		t.Errorf("NewKeyring() accepted duplicate key IDs")
+	}
+}
@@ -0,0 +1,134 @@
+package auth
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// MaxPasswordBytes is as much of a password as bcrypt looks at. Anything
+// longer would be silently truncated, so it's refused instead.
+const MaxPasswordBytes = 72
+
+const (
+	PasswordRequired         = "required"
+	PasswordTooShort         = "too_short"
+	PasswordTooLong          = "too_long"
+	PasswordMissingUppercase = "missing_uppercase"
+	PasswordMissingLowercase = "missing_lowercase"
+	PasswordMissingDigit     = "missing_digit"
+	PasswordMissingSymbol    = "missing_symbol"
+	PasswordBreached         = "breached"
+)
+
+// PasswordPolicy is what a new password has to satisfy. Existing passwords
+// aren't checked against it; it only applies when one is set.
+type PasswordPolicy struct {
+	// MinLength is counted in characters, not bytes.
+	MinLength        int
+	RequireUppercase bool
+	RequireLowercase bool
+	RequireDigit     bool
+	// RequireSymbol asks for something that is neither a letter nor a
+	// digit.
+	RequireSymbol bool
+	// Breached, if set, rejects passwords that have turned up in a breach.
+	Breached *BreachedPasswords
+}
+
+// DefaultPasswordPolicy is used unless the server is configured otherwise.
+var DefaultPasswordPolicy = PasswordPolicy{
+	MinLength:        8,
+	RequireUppercase: true,
+	RequireLowercase: true,
+	RequireDigit:     true,
+}
+
+// PasswordViolation is one rule a password breaks.
+type PasswordViolation struct {
+	Code    string
+	Message string
+}
+
+// PasswordPolicyError lists every rule a password breaks, so the user can
+// fix them all at once.
+type PasswordPolicyError struct {
+	Violations []PasswordViolation
+}
+
+func (e *PasswordPolicyError) Error() string {
+	messages := []string{}
+	for _, v := range e.Violations {
+		messages = append(messages, v.Message)
+	}
+	return strings.Join(messages, "; ")
+}
+
+// Validate returns a *PasswordPolicyError if password breaks the policy.
+// Any other error means the breached-password list couldn't be read.
+func (p PasswordPolicy) Validate(password string) error {
+	if password == "" {
+		return &PasswordPolicyError{Violations: []PasswordViolation{
+			{Code: PasswordRequired, Message: "Password is required"},
+		}}
+	}
+
+	violations := []PasswordViolation{}
+	if utf8.RuneCountInString(password) < p.MinLength {
+		violations = append(violations, PasswordViolation{
+			Code:    PasswordTooShort,
+			Message: fmt.Sprintf("Password must be at least %d characters", p.MinLength),
+		})
+	}
+	if len(password) > MaxPasswordBytes {
+		violations = append(violations, PasswordViolation{
+			Code:    PasswordTooLong,
+			Message: fmt.Sprintf("Password must be at most %d bytes", MaxPasswordBytes),
+		})
+	}
+
+	var upper, lower, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsDigit(r):
+			digit = true
+		case !unicode.IsLetter(r):
+			symbol = true
+		}
+	}
+	if p.RequireUppercase && !upper {
+		violations = append(violations, PasswordViolation{Code: PasswordMissingUppercase, Message: "Password must contain an uppercase letter"})
+	}
+	if p.RequireLowercase && !lower {
+		violations = append(violations, PasswordViolation{Code: PasswordMissingLowercase, Message: "Password must contain a lowercase letter"})
+	}
+	if p.RequireDigit && !digit {
+		violations = append(violations, PasswordViolation{Code: PasswordMissingDigit, Message: "Password must contain a digit"})
+	}
+	if p.RequireSymbol && !symbol {
+		violations = append(violations, PasswordViolation{Code: PasswordMissingSymbol, Message: "Password must contain a symbol"})
+	}
+
+	if p.Breached != nil {
+		breached, err := p.Breached.Contains(password)
+		if err != nil {
+			return err
+		}
+		if breached {
+			violations = append(violations, PasswordViolation{
+				Code:    PasswordBreached,
+				Message: "Password has appeared in a data breach; choose another",
+			})
+		}
+	}
+
+	if len(violations) > 0 {
+		return &PasswordPolicyError{Violations: violations}
+	}
+	return nil
+}
@@ -0,0 +1,142 @@
+package auth
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestPasswordPolicyValidate(t *testing.T) {
+	breached := NewBreachedPasswords(fstest.MapFS{
+		// SHA-1("Password123") is B2E98AD6F6EB8508DD6A14CFA704BAD7F05F6FB1.
+		"B2E98.txt": {Data: []byte("0000000000000000000000000000000000A:0\r\nAD6F6EB8508DD6A14CFA704BAD7F05F6FB1:24556\r\n")},
+	})
+	policy := PasswordPolicy{
+		MinLength:        8,
+		RequireUppercase: true,
+		RequireLowercase: true,
+		RequireDigit:     true,
+		RequireSymbol:    true,
+		Breached:         breached,
+	}
+
+	tests := []struct {
+		name     string
+		password string
+		want     []string
+	}{
+		{
+			name:     "Valid password",
+			password: "Tr0ub4dor&3",
+		},
+		{
+			name:     "Empty",
+			password: "",
+			want:     []string{PasswordRequired},
+		},
+		{
+			name:     "Too short",
+			password: "Ab1!",
+			want:     []string{PasswordTooShort},
+		},
+		{
+			name:     "Length is counted in characters",
+			password: "Äö1!éèàç",
+		},
+		{
+			name:     "Longer than bcrypt allows",
+			password: "Aa1!" + strings.Repeat("x", MaxPasswordBytes),
+			want:     []string{PasswordTooLong},
+		},
+		{
+			name:     "Missing every character class",
+			password: "        ",
+			want:     []string{PasswordMissingUppercase, PasswordMissingLowercase, PasswordMissingDigit},
+		},
+		{
+			name:     "Missing a symbol",
+			password: "Tr0ub4dor3",
+			want:     []string{PasswordMissingSymbol},
+		},
+		{
+			name:     "Breached",
+			password: "Password123",
+			want:     []string{PasswordMissingSymbol, PasswordBreached},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := policy.Validate(tt.password)
+			if tt.want == nil {
+				if err != nil {
+					t.Errorf("Validate() error = %v, want nil", err)
+				}
+				return
+			}
+
+			var policyErr *PasswordPolicyError
+			if !errors.As(err, &policyErr) {
+				t.Fatalf("Validate() error = %v, want a PasswordPolicyError", err)
+			}
+			got := []string{}
+			for _, v := range policyErr.Violations {
+				got = append(got, v.Code)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Validate() violations = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBreachedPasswordsContains(t *testing.T) {
+	breached := NewBreachedPasswords(fstest.MapFS{
+		// SHA-1("password") is 5BAA61E4C9B93F3F0682250B6CF8331B7EE68FD8.
+		"5BAA6.txt": {Data: []byte("003D68EB55068C33ACE09247EE4C639306B:3\n1e4c9b93f3f0682250b6cf8331b7ee68fd8:3861493\n")},
+		// SHA-1("hunter2") is F3BBBD66A63D4BF1747940578EC3D0103530E21D,
+		// listed here only as padding.
+		"F3BBB.txt": {Data: []byte("D66A63D4BF1747940578EC3D0103530E21D:0\n")},
+	})
+
+	tests := []struct {
+		name     string
+		password string
+		want     bool
+	}{
+		{
+			name:     "Listed, lower-case suffix",
+			password: "password",
+			want:     true,
+		},
+		{
+			name:     "Not listed",
+			password: "Password",
+			want:     false,
+		},
+		{
+			name:     "No range file for the prefix",
+			password: "correct horse battery staple",
+			want:     false,
+		},
+		{
+			name:     "Padding entry",
+			password: "hunter2",
+			want:     false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := breached.Contains(tt.password)
+			if err != nil {
+				t.Fatalf("Contains() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Contains(%q) = %v, want %v", tt.password, got, tt.want)
+			}
+		})
+	}
+}
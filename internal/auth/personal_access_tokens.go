@@ -0,0 +1,30 @@
+package auth
+
+import (
+	"strings"
+)
+
+// PersonalAccessTokenPrefix tells personal access tokens apart from JWTs,
+// and makes leaked ones easy for secret scanners to spot.
+const PersonalAccessTokenPrefix = "chirpy_pat_"
+
+// PersonalAccessTokenScopes are the scopes a personal access token may
+// carry. Account settings, including the email and password, stay behind
+// an interactive login.
+var PersonalAccessTokenScopes = []Scope{
+	ScopeChirpsWrite,
+	ScopeChirpsDelete,
+	ScopeProfileWrite,
+}
+
+func MakePersonalAccessToken() (string, error) {
+	token, err := MakeRefreshToken()
+	if err != nil {
+		return "", err
+	}
+	return PersonalAccessTokenPrefix + token, nil
+}
+
+func IsPersonalAccessToken(token string) bool {
+	return strings.HasPrefix(token, PersonalAccessTokenPrefix)
+}
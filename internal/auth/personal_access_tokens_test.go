@@ -0,0 +1,62 @@
+package auth
+
+import (
+	"github.com/google/uuid"
+	"testing"
+	"time"
+)
+
+func TestIsPersonalAccessToken(t *testing.T) {
+	pat, _ := MakePersonalAccessToken()
+	jwt, _ := MakeJWT(uuid.New(), "secret", time.Hour)
+	refreshToken, _ := MakeRefreshToken()
+
+	tests := []struct {
+		name  string
+		token string
+		want  bool
+	}{
+		{
+			name:  "Personal access token",
+			token: pat,
+			want:  true,
+		},
+		{
+			name:  "JWT",
+			token: jwt,
+			want:  false,
+		},
+		{
+			name:  "Refresh token",
+			token: refreshToken,
+			want:  false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsPersonalAccessToken(tt.token); got != tt.want {
+				t.Errorf("IsPersonalAccessToken() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMakePersonalAccessTokenIsUnique(t *testing.T) {
+	first, _ := MakePersonalAccessToken()
+	second, _ := MakePersonalAccessToken()
+	if first == second {
+		t.Errorf("MakePersonalAccessToken() returned the same token twice")
+	}
+	if len(first) != len(PersonalAccessTokenPrefix)+64 {
+		t.Errorf("MakePersonalAccessToken() length = %d, want %d", len(first), len(PersonalAccessTokenPrefix)+64)
+	}
+}
+
+func TestPersonalAccessTokenScopesExcludeAccountSettings(t *testing.T) {
+	for _, scope := range []Scope{ScopeAccount, ScopeAdmin} {
+		if _, err := ReduceScopes(PersonalAccessTokenScopes, []string{string(scope)}); err == nil {
+			t.Errorf("personal access tokens can be granted %s", scope)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"slices"
+	"strings"
+)
+
+type Scope string
+
+const (
+	ScopeChirpsWrite  Scope = "chirps:write"
+	ScopeChirpsDelete Scope = "chirps:delete"
+	// ScopeProfileWrite covers the public profile: username, display
+	// name, bio and location.
+	ScopeProfileWrite Scope = "profile:write"
+	// ScopeAccount covers security settings: the email and password,
+	// sessions, two-factor authentication, data export and deleting the
+	// account.
+	ScopeAccount Scope = "account"
+	// ScopeAdmin opens the /admin endpoints. Only users flagged as admins
+	// can get it, and only by asking for it at login.
+	ScopeAdmin Scope = "admin"
+)
+
+// DefaultScopes are what an ordinary login grants.
+var DefaultScopes = []Scope{
+	ScopeChirpsWrite,
+	ScopeChirpsDelete,
+	ScopeProfileWrite,
+	ScopeAccount,
+}
+
+var ErrInsufficientScope = errors.New("insufficient scope")
+
+// AccessClaims is what a validated access token says about its bearer.
+// A token belongs to either a login session or a personal access token.
+type AccessClaims struct {
+	UserID    uuid.UUID
+	SessionID uuid.UUID
+	TokenID   uuid.UUID
+	Scopes    []Scope
+}
+
+// RequireScopes returns ErrInsufficientScope unless the token carries every
+// one of scopes.
+func (c AccessClaims) RequireScopes(scopes ...Scope) error {
+	for _, scope := range scopes {
+		if !slices.Contains(c.Scopes, scope) {
+			return fmt.Errorf("%w: %s", ErrInsufficientScope, scope)
+		}
+	}
+	return nil
+}
+
+// ReduceScopes narrows granted to the requested scopes, for clients that
+// only want part of what a login would give them. Asking for nothing gets
+// everything granted; asking for a scope outside granted is an error.
+func ReduceScopes(granted []Scope, requested []string) ([]Scope, error) {
+	if len(requested) == 0 {
+		return slices.Clone(granted), nil
+	}
+
+	scopes := []Scope{}
+	for _, name := range requested {
+		scope := Scope(name)
+		if !slices.Contains(granted, scope) {
+			return nil, fmt.Errorf("scope %q can't be granted", name)
+		}
+		if !slices.Contains(scopes, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+	return scopes, nil
+}
+
+func ScopeNames(scopes []Scope) []string {
+	names := []string{}
+	for _, scope := range scopes {
+		names = append(names, string(scope))
+	}
+	return names
+}
+
+func ScopesFromNames(names []string) []Scope {
+	scopes := []Scope{}
+	for _, name := range names {
+		scopes = append(scopes, Scope(name))
+	}
+	return scopes
+}
+
+// FormatScopes joins scopes the way OAuth 2.0 writes them: space separated.
+func FormatScopes(scopes []Scope) string {
+	return strings.Join(ScopeNames(scopes), " ")
+}
+
+func ParseScopes(s string) []Scope {
+	return ScopesFromNames(strings.Fields(s))
+}
@@ -0,0 +1,106 @@
+package auth
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestReduceScopes(t *testing.T) {
+	tests := []struct {
+		name      string
+		granted   []Scope
+		requested []string
+		want      []Scope
+		wantErr   bool
+	}{
+		{
+			name:    "Nothing requested gets everything",
+			granted: DefaultScopes,
+			want:    DefaultScopes,
+		},
+		{
+			name:      "Subset",
+			granted:   DefaultScopes,
+			requested: []string{"chirps:write"},
+			want:      []Scope{ScopeChirpsWrite},
+		},
+		{
+			name:      "Duplicates collapse",
+			granted:   DefaultScopes,
+			requested: []string{"chirps:write", "profile:write", "chirps:write"},
+			want:      []Scope{ScopeChirpsWrite, ScopeProfileWrite},
+		},
+		{
+			name:      "Scope beyond grant",
+			granted:   DefaultScopes,
+			requested: []string{"chirps:write", "admin"},
+			wantErr:   true,
+		},
+		{
+			name:      "Unknown scope",
+			granted:   DefaultScopes,
+			requested: []string{"everything"},
+			wantErr:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ReduceScopes(tt.granted, tt.requested)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ReduceScopes() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ReduceScopes() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRequireScopes(t *testing.T) {
+	claims := AccessClaims{Scopes: []Scope{ScopeChirpsWrite, ScopeProfileWrite}}
+
+	tests := []struct {
+		name    string
+		scopes  []Scope
+		wantErr bool
+	}{
+		{
+			name: "No scopes needed",
+		},
+		{
+			name:   "Held scope",
+			scopes: []Scope{ScopeChirpsWrite},
+		},
+		{
+			name:   "All held",
+			scopes: []Scope{ScopeChirpsWrite, ScopeProfileWrite},
+		},
+		{
+			name:    "Missing scope",
+			scopes:  []Scope{ScopeChirpsWrite, ScopeChirpsDelete},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := claims.RequireScopes(tt.scopes...)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("RequireScopes() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrInsufficientScope) {
+				t.Errorf("RequireScopes() error = %v, want ErrInsufficientScope", err)
+			}
+		})
+	}
+}
+
+func TestParseScopes(t *testing.T) {
+	got := ParseScopes(FormatScopes(DefaultScopes))
+	if !reflect.DeepEqual(got, DefaultScopes) {
+		t.Errorf("ParseScopes(FormatScopes()) = %v, want %v", got, DefaultScopes)
+	}
+}
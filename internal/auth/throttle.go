@@ -0,0 +1,40 @@
+package auth
+
+import (
+	"time"
+)
+
+// LoginThrottle decides how long a run of failed logins holds off the next
+// attempt: nothing for the first few, then doubling delays, then a lockout.
+type LoginThrottle struct {
+	// FreeAttempts is how many failures in a row carry no delay.
+	FreeAttempts int
+	BaseDelay    time.Duration
+	MaxDelay     time.Duration
+	// LockoutAttempts, if set, is the failure count from which every
+	// further failure locks for LockoutDuration instead.
+	LockoutAttempts int
+	LockoutDuration time.Duration
+}
+
+// Delay is how long to refuse logins after the given number of consecutive
+// failures.
+func (t LoginThrottle) Delay(failures int) time.Duration {
+	if t.LocksOut(failures) {
+		return t.LockoutDuration
+	}
+	if failures <= t.FreeAttempts {
+		return 0
+	}
+
+	delay := t.BaseDelay
+	for i := t.FreeAttempts + 1; i < failures && delay < t.MaxDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, t.MaxDelay)
+}
+
+// LocksOut reports whether failures is enough to lock the account.
+func (t LoginThrottle) LocksOut(failures int) bool {
+	return t.LockoutAttempts > 0 && failures >= t.LockoutAttempts
+}
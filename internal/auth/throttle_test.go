@@ -0,0 +1,80 @@
+package auth
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLoginThrottleDelay(t *testing.T) {
+	throttle := LoginThrottle{
+		FreeAttempts:    3,
+		BaseDelay:       time.Second,
+		MaxDelay:        10 * time.Second,
+		LockoutAttempts: 10,
+		LockoutDuration: time.Hour,
+	}
+
+	tests := []struct {
+		name     string
+		failures int
+		want     time.Duration
+	}{
+		{
+			name:     "No failures",
+			failures: 0,
+			want:     0,
+		},
+		{
+			name:     "Last free attempt",
+			failures: 3,
+			want:     0,
+		},
+		{
+			name:     "First delay",
+			failures: 4,
+			want:     time.Second,
+		},
+		{
+			name:     "Delay doubles",
+			failures: 6,
+			want:     4 * time.Second,
+		},
+		{
+			name:     "Delay is capped",
+			failures: 9,
+			want:     10 * time.Second,
+		},
+		{
+			name:     "Lockout",
+			failures: 10,
+			want:     time.Hour,
+		},
+		{
+			name:     "Still locked out",
+			failures: 25,
+			want:     time.Hour,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := throttle.Delay(tt.failures); got != tt.want {
+				t.Errorf("Delay(%d) = %v, want %v", tt.failures, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoginThrottleWithoutLockout(t *testing.T) {
+	throttle := LoginThrottle{
+		BaseDelay: time.Second,
+		MaxDelay:  time.Minute,
+	}
+
+	if throttle.LocksOut(1000) {
+		t.Errorf("LocksOut() = true without LockoutAttempts")
+	}
+	if got := throttle.Delay(1000); got != time.Minute {
+		t.Errorf("Delay(1000) = %v, want %v", got, time.Minute)
+	}
+}
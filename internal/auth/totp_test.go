@@ -0,0 +1,221 @@
+package auth
+
+import (
+	"github.com/google/uuid"
+	"strings"
+	"testing"
+	"time"
+)
+
+// The RFC 6238 appendix B secret, "12345678901234567890" in base32.
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestTOTPCode(t *testing.T) {
+	// Expected codes are the last six digits of the RFC's SHA-1 vectors.
+	tests := []struct {
+		name     string
+		secret   string
+		unixTime int64
+		wantCode string
+		wantErr  bool
+	}{
+		{
+			name:     "RFC vector 59",
+			secret:   rfcSecret,
+			unixTime: 59,
+			wantCode: "287082",
+		},
+		{
+			name:     "RFC vector 1111111109",
+			secret:   rfcSecret,
+			unixTime: 1111111109,
+			wantCode: "081804",
+		},
+		{
+			name:     "RFC vector 1111111111",
+			secret:   rfcSecret,
+			unixTime: 1111111111,
+			wantCode: "050471",
+		},
+		{
+			name:     "RFC vector 1234567890",
+			secret:   rfcSecret,
+			unixTime: 1234567890,
+			wantCode: "005924",
+		},
+		{
+			name:     "RFC vector 2000000000",
+			secret:   rfcSecret,
+			unixTime: 2000000000,
+			wantCode: "279037",
+		},
+		{
+			name:     "Lower-case secret",
+			secret:   strings.ToLower(rfcSecret),
+			unixTime: 59,
+			wantCode: "287082",
+		},
+		{
+			name:     "Invalid secret",
+			secret:   "not base32!",
+			unixTime: 59,
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotCode, err := TOTPCode(tt.secret, time.Unix(tt.unixTime, 0))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("TOTPCode() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if gotCode != tt.wantCode {
+				t.Errorf("TOTPCode() gotCode = %v, want %v", gotCode, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestValidateTOTP(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	current, _ := TOTPCode(rfcSecret, now)
+	previous, _ := TOTPCode(rfcSecret, now.Add(-30*time.Second))
+	stale, _ := TOTPCode(rfcSecret, now.Add(-90*time.Second))
+
+	tests := []struct {
+		name     string
+		code     string
+		wantStep int64
+		wantErr  bool
+	}{
+		{
+			name:     "Current code",
+			code:     current,
+			wantStep: 1111111111 / 30,
+		},
+		{
+			name:     "Previous period within skew",
+			code:     previous,
+			wantStep: 1111111111/30 - 1,
+		},
+		{
+			name:    "Code outside skew",
+			code:    stale,
+			wantErr: true,
+		},
+		{
+			name:    "Wrong code",
+			code:    "000000",
+			wantErr: true,
+		},
+		{
+			name:    "Wrong length",
+			code:    "12345",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotStep, err := ValidateTOTP(rfcSecret, tt.code, now)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateTOTP() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if gotStep != tt.wantStep {
+				t.Errorf("ValidateTOTP() gotStep = %v, want %v", gotStep, tt.wantStep)
+			}
+		})
+	}
+}
+
+func TestGenerateTOTPSecret(t *testing.T) {
+	secret, err := GenerateTOTPSecret()
+	if err != nil {
+		t.Fatalf("GenerateTOTPSecret() error = %v", err)
+	}
+	if _, err := TOTPCode(secret, time.Now()); err != nil {
+		t.Errorf("TOTPCode() rejected generated secret: %v", err)
+	}
+}
+
+func TestNormalizeRecoveryCode(t *testing.T) {
+	codes, err := GenerateRecoveryCodes(3)
+	if err != nil {
+		t.Fatalf("GenerateRecoveryCodes() error = %v", err)
+	}
+
+	tests := []struct {
+		name string
+		code string
+		want string
+	}{
+		{
+			name: "Generated code",
+			code: codes[0],
+			want: strings.ReplaceAll(codes[0], "-", ""),
+		},
+		{
+			name: "Upper case with spaces",
+			code: " ABCDE FGHIJ ",
+			want: "abcdefghij",
+		},
+		{
+			name: "Dashed",
+			code: "abcde-fghij",
+			want: "abcdefghij",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NormalizeRecoveryCode(tt.code); got != tt.want {
+				t.Errorf("NormalizeRecoveryCode() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateTwoFactorChallenge(t *testing.T) {
+	userID := uuid.New()
+	challenge, _ := MakeTwoFactorChallenge(userID, NewHMACKeyring("secret"), time.Minute)
+	accessToken, _ := MakeJWT(userID, "secret", time.Minute)
+
+	tests := []struct {
+		name       string
+		token      string
+		wantUserID uuid.UUID
+		wantErr    bool
+	}{
+		{
+			name:       "Valid challenge",
+			token:      challenge,
+			wantUserID: userID,
+		},
+		{
+			name:       "Access token is not a challenge",
+			token:      accessToken,
+			wantUserID: uuid.Nil,
+			wantErr:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotUserID, err := ValidateTwoFactorChallenge(tt.token, NewHMACKeyring("secret"))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateTwoFactorChallenge() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if gotUserID != tt.wantUserID {
+				t.Errorf("ValidateTwoFactorChallenge() gotUserID = %v, want %v", gotUserID, tt.wantUserID)
+			}
+		})
+	}
+
+	// And the challenge must not work as an access token.
+	if _, err := ValidateJWT(challenge, "secret"); err == nil {
+		t.Errorf("ValidateJWT() accepted a 2FA challenge")
+	}
+}
@@ -7,25 +7,37 @@ package database
 
 import (
 	"context"
+	"database/sql"
+	"time"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
 const createChirp = `-- name: CreateChirp :one
-INSERT INTO chirps(id, created_at, updated_at, body, user_id)
+INSERT INTO chirps(id, created_at, updated_at, body, user_id, in_reply_to, rechirp_of, quote_of)
 VALUES (
-    gen_random_uuid(), NOW(), NOW(), $1, $2
+    gen_random_uuid(), NOW(), NOW(), $1, $2, $3, $4, $5
 )
-RETURNING id, created_at, updated_at, body, user_id
+RETURNING id, created_at, updated_at, body, user_id, in_reply_to, deleted_at, rechirp_of, quote_of, edited_at, hidden_at
 `
 
 type CreateChirpParams struct {
-	Body   string
-	UserID uuid.UUID
+	Body      string
+	UserID    uuid.UUID
+	InReplyTo uuid.NullUUID
+	RechirpOf uuid.NullUUID
+	QuoteOf   uuid.NullUUID
 }
 
 func (q *Queries) CreateChirp(ctx context.Context, arg CreateChirpParams) (Chirp, error) {
-	row := q.db.QueryRowContext(ctx, createChirp, arg.Body, arg.UserID)
+	row := q.db.QueryRowContext(ctx, createChirp,
+		arg.Body,
+		arg.UserID,
+		arg.InReplyTo,
+		arg.RechirpOf,
+		arg.QuoteOf,
+	)
 	var i Chirp
 	err := row.Scan(
 		&i.ID,
@@ -33,22 +45,102 @@ func (q *Queries) CreateChirp(ctx context.Context, arg CreateChirpParams) (Chirp
 		&i.UpdatedAt,
 		&i.Body,
 		&i.UserID,
+		&i.InReplyTo,
+		&i.DeletedAt,
+		&i.RechirpOf,
+		&i.QuoteOf,
+		&i.EditedAt,
+		&i.HiddenAt,
 	)
 	return i, err
 }
 
-const deleteChirpByID = `-- name: DeleteChirpByID :exec
+const deleteChirpByID = `-- name: DeleteChirpByID :execrows
 DELETE FROM chirps
 WHERE ID = $1
+  AND NOT EXISTS (SELECT 1 FROM chirps r WHERE r.in_reply_to = $1 OR r.quote_of = $1)
+`
+
+// Only removes chirps nobody has replied to or quoted; see TombstoneChirp.
+// Plain rechirps go with the original through ON DELETE CASCADE.
+func (q *Queries) DeleteChirpByID(ctx context.Context, id uuid.UUID) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteChirpByID, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const deleteRechirpsOf = `-- name: DeleteRechirpsOf :exec
+DELETE FROM chirps
+WHERE rechirp_of = $1
 `
 
-func (q *Queries) DeleteChirpByID(ctx context.Context, id uuid.UUID) error {
-	_, err := q.db.ExecContext(ctx, deleteChirpByID, id)
+func (q *Queries) DeleteRechirpsOf(ctx context.Context, rechirpOf uuid.NullUUID) error {
+	_, err := q.db.ExecContext(ctx, deleteRechirpsOf, rechirpOf)
 	return err
 }
 
+const getChirpAncestors = `-- name: GetChirpAncestors :many
+WITH RECURSIVE ancestors AS (
+    SELECT parent.id, parent.created_at, parent.updated_at, parent.body, parent.user_id, parent.in_reply_to, parent.deleted_at, parent.rechirp_of, parent.quote_of, parent.edited_at, parent.hidden_at, 1 AS depth
+    FROM chirps parent
+    WHERE parent.id = (SELECT in_reply_to FROM chirps WHERE chirps.id = $1::uuid)
+    UNION ALL
+    SELECT c.id, c.created_at, c.updated_at, c.body, c.user_id, c.in_reply_to, c.deleted_at, c.rechirp_of, c.quote_of, c.edited_at, c.hidden_at, a.depth + 1
+    FROM chirps c
+    JOIN ancestors a ON c.id = a.in_reply_to
+    WHERE a.depth < $2::int
+)
+SELECT c.id, c.created_at, c.updated_at, c.body, c.user_id, c.in_reply_to, c.deleted_at, c.rechirp_of, c.quote_of, c.edited_at, c.hidden_at
+FROM ancestors a
+JOIN chirps c ON c.id = a.id
+ORDER BY a.depth DESC
+`
+
+type GetChirpAncestorsParams struct {
+	ChirpID  uuid.UUID
+	MaxDepth int32
+}
+
+// Walks in_reply_to up to the root, returned root first.
+func (q *Queries) GetChirpAncestors(ctx context.Context, arg GetChirpAncestorsParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getChirpAncestors, arg.ChirpID, arg.MaxDepth)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.InReplyTo,
+			&i.DeletedAt,
+			&i.RechirpOf,
+			&i.QuoteOf,
+			&i.EditedAt,
+			&i.HiddenAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const getChirpByID = `-- name: GetChirpByID :one
-SELECT id, created_at, updated_at, body, user_id
+SELECT id, created_at, updated_at, body, user_id, in_reply_to, deleted_at, rechirp_of, quote_of, edited_at, hidden_at
 FROM chirps
 WHERE ID = $1
 `
@@ -62,18 +154,66 @@ func (q *Queries) GetChirpByID(ctx context.Context, id uuid.UUID) (Chirp, error)
 		&i.UpdatedAt,
 		&i.Body,
 		&i.UserID,
+		&i.InReplyTo,
+		&i.DeletedAt,
+		&i.RechirpOf,
+		&i.QuoteOf,
+		&i.EditedAt,
+		&i.HiddenAt,
 	)
 	return i, err
 }
 
-const getChirps = `-- name: GetChirps :many
-SELECT id, created_at, updated_at, body, user_id
+const getChirpByIDForUpdate = `-- name: GetChirpByIDForUpdate :one
+SELECT id, created_at, updated_at, body, user_id, in_reply_to, deleted_at, rechirp_of, quote_of, edited_at, hidden_at
 FROM chirps
-ORDER BY created_at ASC
+WHERE id = $1
+FOR UPDATE
 `
 
-func (q *Queries) GetChirps(ctx context.Context) ([]Chirp, error) {
-	rows, err := q.db.QueryContext(ctx, getChirps)
+func (q *Queries) GetChirpByIDForUpdate(ctx context.Context, id uuid.UUID) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, getChirpByIDForUpdate, id)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.InReplyTo,
+		&i.DeletedAt,
+		&i.RechirpOf,
+		&i.QuoteOf,
+		&i.EditedAt,
+		&i.HiddenAt,
+	)
+	return i, err
+}
+
+const getChirpDescendants = `-- name: GetChirpDescendants :many
+WITH RECURSIVE descendants AS (
+    SELECT child.id, child.created_at, child.updated_at, child.body, child.user_id, child.in_reply_to, child.deleted_at, child.rechirp_of, child.quote_of, child.edited_at, child.hidden_at
+    FROM chirps child
+    WHERE child.in_reply_to = $1::uuid
+    UNION ALL
+    SELECT c.id, c.created_at, c.updated_at, c.body, c.user_id, c.in_reply_to, c.deleted_at, c.rechirp_of, c.quote_of, c.edited_at, c.hidden_at
+    FROM chirps c
+    JOIN descendants d ON c.in_reply_to = d.id
+)
+SELECT id, created_at, updated_at, body, user_id, in_reply_to, deleted_at, rechirp_of, quote_of, edited_at, hidden_at
+FROM descendants
+ORDER BY created_at ASC, id ASC
+LIMIT $2::int
+`
+
+type GetChirpDescendantsParams struct {
+	ChirpID  uuid.UUID
+	RowLimit int32
+}
+
+// Every reply below a chirp in creation order, capped at row_limit.
+func (q *Queries) GetChirpDescendants(ctx context.Context, arg GetChirpDescendantsParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getChirpDescendants, arg.ChirpID, arg.RowLimit)
 	if err != nil {
 		return nil, err
 	}
@@ -87,6 +227,12 @@ func (q *Queries) GetChirps(ctx context.Context) ([]Chirp, error) {
 			&i.UpdatedAt,
 			&i.Body,
 			&i.UserID,
+			&i.InReplyTo,
+			&i.DeletedAt,
+			&i.RechirpOf,
+			&i.QuoteOf,
+			&i.EditedAt,
+			&i.HiddenAt,
 		); err != nil {
 			return nil, err
 		}
@@ -102,9 +248,11 @@ func (q *Queries) GetChirps(ctx context.Context) ([]Chirp, error) {
 }
 
 const getChirpsByAuthor = `-- name: GetChirpsByAuthor :many
-SELECT id, created_at, updated_at, body, user_id
+SELECT id, created_at, updated_at, body, user_id, in_reply_to, deleted_at, rechirp_of, quote_of, edited_at, hidden_at
 FROM chirps
 WHERE user_id = $1
+  AND deleted_at IS NULL
+  AND hidden_at IS NULL
 ORDER BY created_at ASC
 `
 
@@ -123,6 +271,330 @@ func (q *Queries) GetChirpsByAuthor(ctx context.Context, userID uuid.UUID) ([]Ch
 			&i.UpdatedAt,
 			&i.Body,
 			&i.UserID,
+			&i.InReplyTo,
+			&i.DeletedAt,
+			&i.RechirpOf,
+			&i.QuoteOf,
+			&i.EditedAt,
+			&i.HiddenAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getChirpsByIDs = `-- name: GetChirpsByIDs :many
+SELECT id, created_at, updated_at, body, user_id, in_reply_to, deleted_at, rechirp_of, quote_of, edited_at, hidden_at
+FROM chirps
+WHERE id = ANY($1::uuid[])
+`
+
+func (q *Queries) GetChirpsByIDs(ctx context.Context, ids []uuid.UUID) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getChirpsByIDs, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.InReplyTo,
+			&i.DeletedAt,
+			&i.RechirpOf,
+			&i.QuoteOf,
+			&i.EditedAt,
+			&i.HiddenAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getRechirp = `-- name: GetRechirp :one
+SELECT id, created_at, updated_at, body, user_id, in_reply_to, deleted_at, rechirp_of, quote_of, edited_at, hidden_at
+FROM chirps
+WHERE user_id = $1
+  AND rechirp_of = $2
+`
+
+type GetRechirpParams struct {
+	UserID    uuid.UUID
+	RechirpOf uuid.NullUUID
+}
+
+func (q *Queries) GetRechirp(ctx context.Context, arg GetRechirpParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, getRechirp, arg.UserID, arg.RechirpOf)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.InReplyTo,
+		&i.DeletedAt,
+		&i.RechirpOf,
+		&i.QuoteOf,
+		&i.EditedAt,
+		&i.HiddenAt,
+	)
+	return i, err
+}
+
+const hideUserChirps = `-- name: HideUserChirps :exec
+UPDATE chirps
+SET hidden_at = NOW()
+WHERE user_id = $1
+  AND hidden_at IS NULL
+`
+
+// Hides an account's chirps while it waits out the deletion grace period.
+func (q *Queries) HideUserChirps(ctx context.Context, userID uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, hideUserChirps, userID)
+	return err
+}
+
+const listChirpsAsc = `-- name: ListChirpsAsc :many
+SELECT id, created_at, updated_at, body, user_id, in_reply_to, deleted_at, rechirp_of, quote_of, edited_at, hidden_at
+FROM chirps
+WHERE deleted_at IS NULL
+  AND hidden_at IS NULL
+  AND (created_at, id) > (
+    COALESCE($1::timestamp, '-infinity'::timestamp),
+    COALESCE($2::uuid, '00000000-0000-0000-0000-000000000000'::uuid)
+)
+ORDER BY created_at ASC, id ASC
+LIMIT $3
+`
+
+type ListChirpsAscParams struct {
+	CursorCreatedAt sql.NullTime
+	CursorID        uuid.NullUUID
+	RowLimit        int32
+}
+
+// Listing queries are keyset paginated on (created_at, id). A NULL cursor
+// starts from the beginning (or end) of the range; the COALESCE keeps the
+// comparison sargable so the composite indexes can serve it.
+func (q *Queries) ListChirpsAsc(ctx context.Context, arg ListChirpsAscParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, listChirpsAsc, arg.CursorCreatedAt, arg.CursorID, arg.RowLimit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.InReplyTo,
+			&i.DeletedAt,
+			&i.RechirpOf,
+			&i.QuoteOf,
+			&i.EditedAt,
+			&i.HiddenAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listChirpsByAuthorAsc = `-- name: ListChirpsByAuthorAsc :many
+SELECT id, created_at, updated_at, body, user_id, in_reply_to, deleted_at, rechirp_of, quote_of, edited_at, hidden_at
+FROM chirps
+WHERE user_id = $1
+  AND deleted_at IS NULL
+  AND hidden_at IS NULL
+  AND (created_at, id) > (
+    COALESCE($2::timestamp, '-infinity'::timestamp),
+    COALESCE($3::uuid, '00000000-0000-0000-0000-000000000000'::uuid)
+)
+ORDER BY created_at ASC, id ASC
+LIMIT $4
+`
+
+type ListChirpsByAuthorAscParams struct {
+	AuthorID        uuid.UUID
+	CursorCreatedAt sql.NullTime
+	CursorID        uuid.NullUUID
+	RowLimit        int32
+}
+
+func (q *Queries) ListChirpsByAuthorAsc(ctx context.Context, arg ListChirpsByAuthorAscParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, listChirpsByAuthorAsc,
+		arg.AuthorID,
+		arg.CursorCreatedAt,
+		arg.CursorID,
+		arg.RowLimit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.InReplyTo,
+			&i.DeletedAt,
+			&i.RechirpOf,
+			&i.QuoteOf,
+			&i.EditedAt,
+			&i.HiddenAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listChirpsByAuthorDesc = `-- name: ListChirpsByAuthorDesc :many
+SELECT id, created_at, updated_at, body, user_id, in_reply_to, deleted_at, rechirp_of, quote_of, edited_at, hidden_at
+FROM chirps
+WHERE user_id = $1
+  AND deleted_at IS NULL
+  AND hidden_at IS NULL
+  AND (created_at, id) < (
+    COALESCE($2::timestamp, 'infinity'::timestamp),
+    COALESCE($3::uuid, 'ffffffff-ffff-ffff-ffff-ffffffffffff'::uuid)
+)
+ORDER BY created_at DESC, id DESC
+LIMIT $4
+`
+
+type ListChirpsByAuthorDescParams struct {
+	AuthorID        uuid.UUID
+	CursorCreatedAt sql.NullTime
+	CursorID        uuid.NullUUID
+	RowLimit        int32
+}
+
+func (q *Queries) ListChirpsByAuthorDesc(ctx context.Context, arg ListChirpsByAuthorDescParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, listChirpsByAuthorDesc,
+		arg.AuthorID,
+		arg.CursorCreatedAt,
+		arg.CursorID,
+		arg.RowLimit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.InReplyTo,
+			&i.DeletedAt,
+			&i.RechirpOf,
+			&i.QuoteOf,
+			&i.EditedAt,
+			&i.HiddenAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listChirpsDesc = `-- name: ListChirpsDesc :many
+SELECT id, created_at, updated_at, body, user_id, in_reply_to, deleted_at, rechirp_of, quote_of, edited_at, hidden_at
+FROM chirps
+WHERE deleted_at IS NULL
+  AND hidden_at IS NULL
+  AND (created_at, id) < (
+    COALESCE($1::timestamp, 'infinity'::timestamp),
+    COALESCE($2::uuid, 'ffffffff-ffff-ffff-ffff-ffffffffffff'::uuid)
+)
+ORDER BY created_at DESC, id DESC
+LIMIT $3
+`
+
+type ListChirpsDescParams struct {
+	CursorCreatedAt sql.NullTime
+	CursorID        uuid.NullUUID
+	RowLimit        int32
+}
+
+func (q *Queries) ListChirpsDesc(ctx context.Context, arg ListChirpsDescParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, listChirpsDesc, arg.CursorCreatedAt, arg.CursorID, arg.RowLimit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.InReplyTo,
+			&i.DeletedAt,
+			&i.RechirpOf,
+			&i.QuoteOf,
+			&i.EditedAt,
+			&i.HiddenAt,
 		); err != nil {
 			return nil, err
 		}
@@ -137,6 +609,304 @@ func (q *Queries) GetChirpsByAuthor(ctx context.Context, userID uuid.UUID) ([]Ch
 	return items, nil
 }
 
+const listRepliesAsc = `-- name: ListRepliesAsc :many
+SELECT id, created_at, updated_at, body, user_id, in_reply_to, deleted_at, rechirp_of, quote_of, edited_at, hidden_at
+FROM chirps
+WHERE in_reply_to = $1::uuid
+  AND (created_at, id) > (
+    COALESCE($2::timestamp, '-infinity'::timestamp),
+    COALESCE($3::uuid, '00000000-0000-0000-0000-000000000000'::uuid)
+)
+ORDER BY created_at ASC, id ASC
+LIMIT $4
+`
+
+type ListRepliesAscParams struct {
+	ParentID        uuid.UUID
+	CursorCreatedAt sql.NullTime
+	CursorID        uuid.NullUUID
+	RowLimit        int32
+}
+
+// Replies include tombstones so a thread keeps its shape.
+func (q *Queries) ListRepliesAsc(ctx context.Context, arg ListRepliesAscParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, listRepliesAsc,
+		arg.ParentID,
+		arg.CursorCreatedAt,
+		arg.CursorID,
+		arg.RowLimit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.InReplyTo,
+			&i.DeletedAt,
+			&i.RechirpOf,
+			&i.QuoteOf,
+			&i.EditedAt,
+			&i.HiddenAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listRepliesDesc = `-- name: ListRepliesDesc :many
+SELECT id, created_at, updated_at, body, user_id, in_reply_to, deleted_at, rechirp_of, quote_of, edited_at, hidden_at
+FROM chirps
+WHERE in_reply_to = $1::uuid
+  AND (created_at, id) < (
+    COALESCE($2::timestamp, 'infinity'::timestamp),
+    COALESCE($3::uuid, 'ffffffff-ffff-ffff-ffff-ffffffffffff'::uuid)
+)
+ORDER BY created_at DESC, id DESC
+LIMIT $4
+`
+
+type ListRepliesDescParams struct {
+	ParentID        uuid.UUID
+	CursorCreatedAt sql.NullTime
+	CursorID        uuid.NullUUID
+	RowLimit        int32
+}
+
+func (q *Queries) ListRepliesDesc(ctx context.Context, arg ListRepliesDescParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, listRepliesDesc,
+		arg.ParentID,
+		arg.CursorCreatedAt,
+		arg.CursorID,
+		arg.RowLimit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.InReplyTo,
+			&i.DeletedAt,
+			&i.RechirpOf,
+			&i.QuoteOf,
+			&i.EditedAt,
+			&i.HiddenAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listTimelineAsc = `-- name: ListTimelineAsc :many
+SELECT c.id, c.created_at, c.updated_at, c.body, c.user_id, c.in_reply_to, c.deleted_at, c.rechirp_of, c.quote_of, c.edited_at, c.hidden_at
+FROM chirps c
+WHERE c.deleted_at IS NULL
+  AND c.hidden_at IS NULL
+  AND (c.user_id = $1::uuid
+       OR c.user_id IN (SELECT followee_id FROM follows WHERE follower_id = $1::uuid))
+  AND (c.created_at, c.id) > (
+    COALESCE($2::timestamp, '-infinity'::timestamp),
+    COALESCE($3::uuid, '00000000-0000-0000-0000-000000000000'::uuid)
+)
+ORDER BY c.created_at ASC, c.id ASC
+LIMIT $4
+`
+
+type ListTimelineAscParams struct {
+	UserID          uuid.UUID
+	CursorCreatedAt sql.NullTime
+	CursorID        uuid.NullUUID
+	RowLimit        int32
+}
+
+// The home timeline: the user's own chirps and those of everyone they follow.
+func (q *Queries) ListTimelineAsc(ctx context.Context, arg ListTimelineAscParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, listTimelineAsc,
+		arg.UserID,
+		arg.CursorCreatedAt,
+		arg.CursorID,
+		arg.RowLimit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.InReplyTo,
+			&i.DeletedAt,
+			&i.RechirpOf,
+			&i.QuoteOf,
+			&i.EditedAt,
+			&i.HiddenAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listTimelineDesc = `-- name: ListTimelineDesc :many
+SELECT c.id, c.created_at, c.updated_at, c.body, c.user_id, c.in_reply_to, c.deleted_at, c.rechirp_of, c.quote_of, c.edited_at, c.hidden_at
+FROM chirps c
+WHERE c.deleted_at IS NULL
+  AND c.hidden_at IS NULL
+  AND (c.user_id = $1::uuid
+       OR c.user_id IN (SELECT followee_id FROM follows WHERE follower_id = $1::uuid))
+  AND (c.created_at, c.id) < (
+    COALESCE($2::timestamp, 'infinity'::timestamp),
+    COALESCE($3::uuid, 'ffffffff-ffff-ffff-ffff-ffffffffffff'::uuid)
+)
+ORDER BY c.created_at DESC, c.id DESC
+LIMIT $4
+`
+
+type ListTimelineDescParams struct {
+	UserID          uuid.UUID
+	CursorCreatedAt sql.NullTime
+	CursorID        uuid.NullUUID
+	RowLimit        int32
+}
+
+func (q *Queries) ListTimelineDesc(ctx context.Context, arg ListTimelineDescParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, listTimelineDesc,
+		arg.UserID,
+		arg.CursorCreatedAt,
+		arg.CursorID,
+		arg.RowLimit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.InReplyTo,
+			&i.DeletedAt,
+			&i.RechirpOf,
+			&i.QuoteOf,
+			&i.EditedAt,
+			&i.HiddenAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listUserChirpsWithResponses = `-- name: ListUserChirpsWithResponses :many
+SELECT c.id
+FROM chirps c
+WHERE c.user_id = $1
+  AND EXISTS (
+    SELECT 1
+    FROM chirps r
+    WHERE (r.in_reply_to = c.id OR r.quote_of = c.id)
+      AND r.user_id <> c.user_id
+  )
+`
+
+// The user's chirps that someone else's reply or quote points at.
+func (q *Queries) ListUserChirpsWithResponses(ctx context.Context, userID uuid.UUID) ([]uuid.UUID, error) {
+	rows, err := q.db.QueryContext(ctx, listUserChirpsWithResponses, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		items = append(items, id)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const reassignChirp = `-- name: ReassignChirp :exec
+UPDATE chirps
+SET user_id = $1, rechirp_of = NULL
+WHERE id = $2
+`
+
+type ReassignChirpParams struct {
+	UserID uuid.UUID
+	ID     uuid.UUID
+}
+
+// Moves a tombstoned chirp to another owner. rechirp_of is dropped so the
+// new owner can't end up with two rechirps of the same chirp.
+func (q *Queries) ReassignChirp(ctx context.Context, arg ReassignChirpParams) error {
+	_, err := q.db.ExecContext(ctx, reassignChirp, arg.UserID, arg.ID)
+	return err
+}
+
 const resetChirps = `-- name: ResetChirps :exec
 DELETE FROM chirps
 `
@@ -145,3 +915,190 @@ func (q *Queries) ResetChirps(ctx context.Context) error {
 	_, err := q.db.ExecContext(ctx, resetChirps)
 	return err
 }
+
+const searchChirpsAsc = `-- name: SearchChirpsAsc :many
+SELECT c.id, c.created_at, ts_rank(to_tsvector('english', c.body), query)::real AS rank
+FROM chirps c, websearch_to_tsquery('english', $1::text) query
+WHERE to_tsvector('english', c.body) @@ query
+  AND c.deleted_at IS NULL
+  AND c.hidden_at IS NULL
+  AND ($2::uuid IS NULL OR c.user_id = $2)
+  AND (ts_rank(to_tsvector('english', c.body), query), c.created_at, c.id) > (
+    COALESCE($3::real, '-infinity'::real),
+    COALESCE($4::timestamp, '-infinity'::timestamp),
+    COALESCE($5::uuid, '00000000-0000-0000-0000-000000000000'::uuid)
+)
+ORDER BY rank ASC, c.created_at ASC, c.id ASC
+LIMIT $6
+`
+
+type SearchChirpsAscParams struct {
+	Query           string
+	AuthorID        uuid.NullUUID
+	CursorRank      sql.NullFloat64
+	CursorCreatedAt sql.NullTime
+	CursorID        uuid.NullUUID
+	RowLimit        int32
+}
+
+type SearchChirpsAscRow struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	Rank      float32
+}
+
+func (q *Queries) SearchChirpsAsc(ctx context.Context, arg SearchChirpsAscParams) ([]SearchChirpsAscRow, error) {
+	rows, err := q.db.QueryContext(ctx, searchChirpsAsc,
+		arg.Query,
+		arg.AuthorID,
+		arg.CursorRank,
+		arg.CursorCreatedAt,
+		arg.CursorID,
+		arg.RowLimit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []SearchChirpsAscRow
+	for rows.Next() {
+		var i SearchChirpsAscRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.Rank,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const searchChirpsDesc = `-- name: SearchChirpsDesc :many
+SELECT c.id, c.created_at, ts_rank(to_tsvector('english', c.body), query)::real AS rank
+FROM chirps c, websearch_to_tsquery('english', $1::text) query
+WHERE to_tsvector('english', c.body) @@ query
+  AND c.deleted_at IS NULL
+  AND c.hidden_at IS NULL
+  AND ($2::uuid IS NULL OR c.user_id = $2)
+  AND (ts_rank(to_tsvector('english', c.body), query), c.created_at, c.id) < (
+    COALESCE($3::real, 'infinity'::real),
+    COALESCE($4::timestamp, 'infinity'::timestamp),
+    COALESCE($5::uuid, 'ffffffff-ffff-ffff-ffff-ffffffffffff'::uuid)
+)
+ORDER BY rank DESC, c.created_at DESC, c.id DESC
+LIMIT $6
+`
+
+type SearchChirpsDescParams struct {
+	Query           string
+	AuthorID        uuid.NullUUID
+	CursorRank      sql.NullFloat64
+	CursorCreatedAt sql.NullTime
+	CursorID        uuid.NullUUID
+	RowLimit        int32
+}
+
+type SearchChirpsDescRow struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	Rank      float32
+}
+
+// Full-text search, best match first. query accepts websearch syntax, so
+// "quoted phrases", OR and -exclusions all work. The expression must match
+// chirps_body_search_idx for the index to be used.
+func (q *Queries) SearchChirpsDesc(ctx context.Context, arg SearchChirpsDescParams) ([]SearchChirpsDescRow, error) {
+	rows, err := q.db.QueryContext(ctx, searchChirpsDesc,
+		arg.Query,
+		arg.AuthorID,
+		arg.CursorRank,
+		arg.CursorCreatedAt,
+		arg.CursorID,
+		arg.RowLimit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []SearchChirpsDescRow
+	for rows.Next() {
+		var i SearchChirpsDescRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.Rank,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const tombstoneChirp = `-- name: TombstoneChirp :exec
+UPDATE chirps
+SET body = '', deleted_at = NOW(), updated_at = NOW()
+WHERE id = $1
+`
+
+// Keeps the row so replies stay attached to their thread.
+func (q *Queries) TombstoneChirp(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, tombstoneChirp, id)
+	return err
+}
+
+const unhideUserChirps = `-- name: UnhideUserChirps :exec
+UPDATE chirps
+SET hidden_at = NULL
+WHERE user_id = $1
+`
+
+func (q *Queries) UnhideUserChirps(ctx context.Context, userID uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, unhideUserChirps, userID)
+	return err
+}
+
+const updateChirpBody = `-- name: UpdateChirpBody :one
+UPDATE chirps
+SET body = $1, edited_at = NOW(), updated_at = NOW()
+WHERE id = $2
+RETURNING id, created_at, updated_at, body, user_id, in_reply_to, deleted_at, rechirp_of, quote_of, edited_at, hidden_at
+`
+
+type UpdateChirpBodyParams struct {
+	Body string
+	ID   uuid.UUID
+}
+
+func (q *Queries) UpdateChirpBody(ctx context.Context, arg UpdateChirpBodyParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, updateChirpBody, arg.Body, arg.ID)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.InReplyTo,
+		&i.DeletedAt,
+		&i.RechirpOf,
+		&i.QuoteOf,
+		&i.EditedAt,
+		&i.HiddenAt,
+	)
+	return i, err
+}
@@ -11,59 +11,100 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
 const createRefreshToken = `-- name: CreateRefreshToken :one
-INSERT INTO refresh_tokens (token, created_at, updated_at, user_id, expires_at, revoked_at)
+INSERT INTO refresh_tokens (token_hash, created_at, updated_at, user_id, session_id, expires_at, revoked_at)
 VALUES (
-    $1, NOW(), NOW(), $2, $3, NULL
+    $1, NOW(), NOW(), $2, $3, $4, NULL
 )
-RETURNING token, created_at, updated_at, user_id, expires_at, revoked_at
+RETURNING token_hash, created_at, updated_at, user_id, expires_at, revoked_at, session_id, rotated_at
 `
 
 type CreateRefreshTokenParams struct {
-	Token     string
+	TokenHash string
 	UserID    uuid.UUID
+	SessionID uuid.UUID
 	ExpiresAt time.Time
 }
 
 func (q *Queries) CreateRefreshToken(ctx context.Context, arg CreateRefreshTokenParams) (RefreshToken, error) {
-	row := q.db.QueryRowContext(ctx, createRefreshToken, arg.Token, arg.UserID, arg.ExpiresAt)
+	row := q.db.QueryRowContext(ctx, createRefreshToken,
+		arg.TokenHash,
+		arg.UserID,
+		arg.SessionID,
+		arg.ExpiresAt,
+	)
 	var i RefreshToken
 	err := row.Scan(
-		&i.Token,
+		&i.TokenHash,
 		&i.CreatedAt,
 		&i.UpdatedAt,
 		&i.UserID,
 		&i.ExpiresAt,
 		&i.RevokedAt,
+		&i.SessionID,
+		&i.RotatedAt,
+	)
+	return i, err
+}
+
+const getRotatedRefreshToken = `-- name: GetRotatedRefreshToken :one
+SELECT token_hash, user_id, session_id, rotated_at
+FROM refresh_tokens
+WHERE token_hash = $1
+  AND rotated_at IS NOT NULL
+`
+
+type GetRotatedRefreshTokenRow struct {
+	TokenHash string
+	UserID    uuid.UUID
+	SessionID uuid.UUID
+	RotatedAt sql.NullTime
+}
+
+func (q *Queries) GetRotatedRefreshToken(ctx context.Context, tokenHash string) (GetRotatedRefreshTokenRow, error) {
+	row := q.db.QueryRowContext(ctx, getRotatedRefreshToken, tokenHash)
+	var i GetRotatedRefreshTokenRow
+	err := row.Scan(
+		&i.TokenHash,
+		&i.UserID,
+		&i.SessionID,
+		&i.RotatedAt,
 	)
 	return i, err
 }
 
 const getUserFromRefreshToken = `-- name: GetUserFromRefreshToken :one
-SELECT token, user_id, expires_at, revoked_at
+SELECT refresh_tokens.token_hash, refresh_tokens.user_id, refresh_tokens.session_id, refresh_tokens.expires_at, refresh_tokens.revoked_at, sessions.scopes
 FROM refresh_tokens
-WHERE token = $1
-  AND revoked_at IS NULL
-  AND expires_at > NOW()
+JOIN sessions ON sessions.id = refresh_tokens.session_id
+WHERE refresh_tokens.token_hash = $1
+  AND refresh_tokens.revoked_at IS NULL
+  AND refresh_tokens.expires_at > NOW()
+  AND sessions.revoked_at IS NULL
 `
 
 type GetUserFromRefreshTokenRow struct {
-	Token     string
+	TokenHash string
 	UserID    uuid.UUID
+	SessionID uuid.UUID
 	ExpiresAt time.Time
 	RevokedAt sql.NullTime
+	Scopes    []string
 }
 
-func (q *Queries) GetUserFromRefreshToken(ctx context.Context, token string) (GetUserFromRefreshTokenRow, error) {
-	row := q.db.QueryRowContext(ctx, getUserFromRefreshToken, token)
+func (q *Queries) GetUserFromRefreshToken(ctx context.Context, tokenHash string) (GetUserFromRefreshTokenRow, error) {
+	row := q.db.QueryRowContext(ctx, getUserFromRefreshToken, tokenHash)
 	var i GetUserFromRefreshTokenRow
 	err := row.Scan(
-		&i.Token,
+		&i.TokenHash,
 		&i.UserID,
+		&i.SessionID,
 		&i.ExpiresAt,
 		&i.RevokedAt,
+		pq.Array(&i.Scopes),
 	)
 	return i, err
 }
@@ -71,11 +112,50 @@ func (q *Queries) GetUserFromRefreshToken(ctx context.Context, token string) (Ge
 const revokeRefreshToken = `-- name: RevokeRefreshToken :exec
 UPDATE refresh_tokens
 SET revoked_at = NOW(), updated_at = NOW()
-WHERE token = $1
+WHERE token_hash = $1
+  AND revoked_at IS NULL
+`
+
+func (q *Queries) RevokeRefreshToken(ctx context.Context, tokenHash string) error {
+	_, err := q.db.ExecContext(ctx, revokeRefreshToken, tokenHash)
+	return err
+}
+
+const revokeRefreshTokenSession = `-- name: RevokeRefreshTokenSession :exec
+UPDATE sessions
+SET revoked_at = NOW()
+WHERE id = (SELECT session_id FROM refresh_tokens WHERE token_hash = $1)
+  AND revoked_at IS NULL
+`
+
+func (q *Queries) RevokeRefreshTokenSession(ctx context.Context, tokenHash string) error {
+	_, err := q.db.ExecContext(ctx, revokeRefreshTokenSession, tokenHash)
+	return err
+}
+
+const revokeUserRefreshTokens = `-- name: RevokeUserRefreshTokens :exec
+UPDATE refresh_tokens
+SET revoked_at = NOW(), updated_at = NOW()
+WHERE user_id = $1
   AND revoked_at IS NULL
 `
 
-func (q *Queries) RevokeRefreshToken(ctx context.Context, token string) error {
-	_, err := q.db.ExecContext(ctx, revokeRefreshToken, token)
+func (q *Queries) RevokeUserRefreshTokens(ctx context.Context, userID uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, revokeUserRefreshTokens, userID)
 	return err
 }
+
+const rotateRefreshToken = `-- name: RotateRefreshToken :execrows
+UPDATE refresh_tokens
+SET revoked_at = NOW(), rotated_at = NOW(), updated_at = NOW()
+WHERE token_hash = $1
+  AND revoked_at IS NULL
+`
+
+func (q *Queries) RotateRefreshToken(ctx context.Context, tokenHash string) (int64, error) {
+	result, err := q.db.ExecContext(ctx, rotateRefreshToken, tokenHash)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
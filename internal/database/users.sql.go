@@ -7,26 +7,39 @@ package database
 
 import (
 	"context"
+	"database/sql"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
 const createUser = `-- name: CreateUser :one
-INSERT INTO users(id, created_at, updated_at, email, hashed_password)
+INSERT INTO users(id, created_at, updated_at, email, hashed_password, username, display_name, bio, location)
 VALUES (
-    gen_random_uuid(), NOW(), NOW(), $1, $2
+    gen_random_uuid(), NOW(), NOW(), $1, $2, $3, $4, $5, $6
 )
-RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, username, display_name, bio, location, email_verified, deleted_at, is_admin
 `
 
 type CreateUserParams struct {
 	Email          string
 	HashedPassword string
+	Username       sql.NullString
+	DisplayName    string
+	Bio            string
+	Location       string
 }
 
 func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, error) {
-	row := q.db.QueryRowContext(ctx, createUser, arg.Email, arg.HashedPassword)
+	row := q.db.QueryRowContext(ctx, createUser,
+		arg.Email,
+		arg.HashedPassword,
+		arg.Username,
+		arg.DisplayName,
+		arg.Bio,
+		arg.Location,
+	)
 	var i User
 	err := row.Scan(
 		&i.ID,
@@ -35,22 +48,30 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, e
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.Username,
+		&i.DisplayName,
+		&i.Bio,
+		&i.Location,
+		&i.EmailVerified,
+		&i.DeletedAt,
+		&i.IsAdmin,
 	)
 	return i, err
 }
 
 const getUser = `-- name: GetUser :one
-SELECT id, email, created_at, updated_at, is_chirpy_red
+SELECT id, email, created_at, updated_at, is_chirpy_red, email_verified
 FROM users
 WHERE id = $1
 `
 
 type GetUserRow struct {
-	ID          uuid.UUID
-	Email       string
-	CreatedAt   time.Time
-	UpdatedAt   time.Time
-	IsChirpyRed bool
+	ID            uuid.UUID
+	Email         string
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+	IsChirpyRed   bool
+	EmailVerified bool
 }
 
 func (q *Queries) GetUser(ctx context.Context, id uuid.UUID) (GetUserRow, error) {
@@ -62,63 +83,423 @@ func (q *Queries) GetUser(ctx context.Context, id uuid.UUID) (GetUserRow, error)
 		&i.CreatedAt,
 		&i.UpdatedAt,
 		&i.IsChirpyRed,
+		&i.EmailVerified,
 	)
 	return i, err
 }
 
 const getUserByEmail = `-- name: GetUserByEmail :one
-SELECT id, email, created_at, updated_at, hashed_password, is_chirpy_red
+SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red, username, display_name, bio, location, email_verified, deleted_at, is_admin
 FROM users
 WHERE email = $1
 `
 
-type GetUserByEmailRow struct {
+func (q *Queries) GetUserByEmail(ctx context.Context, email string) (User, error) {
+	row := q.db.QueryRowContext(ctx, getUserByEmail, email)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.Username,
+		&i.DisplayName,
+		&i.Bio,
+		&i.Location,
+		&i.EmailVerified,
+		&i.DeletedAt,
+		&i.IsAdmin,
+	)
+	return i, err
+}
+
+const getUserByID = `-- name: GetUserByID :one
+SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red, username, display_name, bio, location, email_verified, deleted_at, is_admin
+FROM users
+WHERE id = $1
+`
+
+func (q *Queries) GetUserByID(ctx context.Context, id uuid.UUID) (User, error) {
+	row := q.db.QueryRowContext(ctx, getUserByID, id)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.Username,
+		&i.DisplayName,
+		&i.Bio,
+		&i.Location,
+		&i.EmailVerified,
+		&i.DeletedAt,
+		&i.IsAdmin,
+	)
+	return i, err
+}
+
+const getUserByIDForUpdate = `-- name: GetUserByIDForUpdate :one
+SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red, username, display_name, bio, location, email_verified, deleted_at, is_admin
+FROM users
+WHERE id = $1
+FOR UPDATE
+`
+
+func (q *Queries) GetUserByIDForUpdate(ctx context.Context, id uuid.UUID) (User, error) {
+	row := q.db.QueryRowContext(ctx, getUserByIDForUpdate, id)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.Username,
+		&i.DisplayName,
+		&i.Bio,
+		&i.Location,
+		&i.EmailVerified,
+		&i.DeletedAt,
+		&i.IsAdmin,
+	)
+	return i, err
+}
+
+const getUserProfileByUsername = `-- name: GetUserProfileByUsername :one
+SELECT u.id, u.created_at, u.username, u.display_name, u.bio, u.location, u.is_chirpy_red,
+    (SELECT COUNT(*) FROM follows f WHERE f.followee_id = u.id)::bigint AS follower_count,
+    (SELECT COUNT(*) FROM follows f WHERE f.follower_id = u.id)::bigint AS following_count,
+    (SELECT COUNT(*) FROM chirps c WHERE c.user_id = u.id AND c.deleted_at IS NULL)::bigint AS chirp_count
+FROM users u
+WHERE u.username = $1
+  AND u.deleted_at IS NULL
+`
+
+type GetUserProfileByUsernameRow struct {
 	ID             uuid.UUID
-	Email          string
 	CreatedAt      time.Time
-	UpdatedAt      time.Time
-	HashedPassword string
+	Username       sql.NullString
+	DisplayName    string
+	Bio            string
+	Location       string
 	IsChirpyRed    bool
+	FollowerCount  int64
+	FollowingCount int64
+	ChirpCount     int64
 }
 
-func (q *Queries) GetUserByEmail(ctx context.Context, email string) (GetUserByEmailRow, error) {
-	row := q.db.QueryRowContext(ctx, getUserByEmail, email)
-	var i GetUserByEmailRow
+func (q *Queries) GetUserProfileByUsername(ctx context.Context, username sql.NullString) (GetUserProfileByUsernameRow, error) {
+	row := q.db.QueryRowContext(ctx, getUserProfileByUsername, username)
+	var i GetUserProfileByUsernameRow
 	err := row.Scan(
 		&i.ID,
+		&i.CreatedAt,
+		&i.Username,
+		&i.DisplayName,
+		&i.Bio,
+		&i.Location,
+		&i.IsChirpyRed,
+		&i.FollowerCount,
+		&i.FollowingCount,
+		&i.ChirpCount,
+	)
+	return i, err
+}
+
+const getUsersByEmailLocalPart = `-- name: GetUsersByEmailLocalPart :many
+SELECT id, lower(split_part(email, '@', 1))::text AS handle
+FROM users
+WHERE lower(split_part(email, '@', 1)) = ANY($1::text[])
+  AND deleted_at IS NULL
+`
+
+type GetUsersByEmailLocalPartRow struct {
+	ID     uuid.UUID
+	Handle string
+}
+
+// Resolves @mentions. A local part shared by several addresses comes back
+// once per user; callers should treat that handle as ambiguous.
+func (q *Queries) GetUsersByEmailLocalPart(ctx context.Context, handles []string) ([]GetUsersByEmailLocalPartRow, error) {
+	rows, err := q.db.QueryContext(ctx, getUsersByEmailLocalPart, pq.Array(handles))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetUsersByEmailLocalPartRow
+	for rows.Next() {
+		var i GetUsersByEmailLocalPartRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Handle,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getUsersByUsername = `-- name: GetUsersByUsername :many
+SELECT id, username::text AS handle
+FROM users
+WHERE username = ANY($1::text[])
+  AND deleted_at IS NULL
+`
+
+type GetUsersByUsernameRow struct {
+	ID     uuid.UUID
+	Handle string
+}
+
+func (q *Queries) GetUsersByUsername(ctx context.Context, usernames []string) ([]GetUsersByUsernameRow, error) {
+	rows, err := q.db.QueryContext(ctx, getUsersByUsername, pq.Array(usernames))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetUsersByUsernameRow
+	for rows.Next() {
+		var i GetUsersByUsernameRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Handle,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listPurgeableUsers = `-- name: ListPurgeableUsers :many
+SELECT id
+FROM users
+WHERE deleted_at < $1::timestamp
+`
+
+// Accounts whose deletion grace period is over.
+func (q *Queries) ListPurgeableUsers(ctx context.Context, deletedBefore time.Time) ([]uuid.UUID, error) {
+	rows, err := q.db.QueryContext(ctx, listPurgeableUsers, deletedBefore)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		items = append(items, id)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const markEmailVerified = `-- name: MarkEmailVerified :one
+UPDATE users
+SET email_verified = true, updated_at = NOW()
+WHERE id = $1 AND email = $2
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, username, display_name, bio, location, email_verified, deleted_at, is_admin
+`
+
+type MarkEmailVerifiedParams struct {
+	ID    uuid.UUID
+	Email string
+}
+
+// Only verifies the address the token was sent to; a token issued before an
+// email change matches no row.
+func (q *Queries) MarkEmailVerified(ctx context.Context, arg MarkEmailVerifiedParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, markEmailVerified, arg.ID, arg.Email)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
 		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.Username,
+		&i.DisplayName,
+		&i.Bio,
+		&i.Location,
+		&i.EmailVerified,
+		&i.DeletedAt,
+		&i.IsAdmin,
+	)
+	return i, err
+}
+
+const patchUser = `-- name: PatchUser :one
+UPDATE users
+SET email = $1,
+    hashed_password = $2,
+    username = $3,
+    display_name = $4,
+    bio = $5,
+    location = $6,
+    email_verified = $7,
+    updated_at = NOW()
+WHERE id = $8
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, username, display_name, bio, location, email_verified, deleted_at, is_admin
+`
+
+type PatchUserParams struct {
+	Email          string
+	HashedPassword string
+	Username       sql.NullString
+	DisplayName    string
+	Bio            string
+	Location       string
+	EmailVerified  bool
+	ID             uuid.UUID
+}
+
+// Writes back every field; callers lock the row with GetUserByIDForUpdate
+// and only change what the patch touched.
+func (q *Queries) PatchUser(ctx context.Context, arg PatchUserParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, patchUser,
+		arg.Email,
+		arg.HashedPassword,
+		arg.Username,
+		arg.DisplayName,
+		arg.Bio,
+		arg.Location,
+		arg.EmailVerified,
+		arg.ID,
+	)
+	var i User
+	err := row.Scan(
+		&i.ID,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.Username,
+		&i.DisplayName,
+		&i.Bio,
+		&i.Location,
+		&i.EmailVerified,
+		&i.DeletedAt,
+		&i.IsAdmin,
 	)
 	return i, err
 }
 
+const purgeUser = `-- name: PurgeUser :execrows
+DELETE FROM users
+WHERE id = $1
+  AND deleted_at IS NOT NULL
+`
+
+// Hard-deletes an account. Its chirps, tokens and the rest go with it
+// through ON DELETE CASCADE.
+func (q *Queries) PurgeUser(ctx context.Context, id uuid.UUID) (int64, error) {
+	result, err := q.db.ExecContext(ctx, purgeUser, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
 const resetUsers = `-- name: ResetUsers :exec
 DELETE FROM users
+WHERE id <> $1
 `
 
-func (q *Queries) ResetUsers(ctx context.Context) error {
-	_, err := q.db.ExecContext(ctx, resetUsers)
+// Keeps the deleted-user placeholder, which only its migration creates.
+func (q *Queries) ResetUsers(ctx context.Context, keepID uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, resetUsers, keepID)
 	return err
 }
 
+const restoreUser = `-- name: RestoreUser :exec
+UPDATE users
+SET deleted_at = NULL, updated_at = NOW()
+WHERE id = $1
+`
+
+func (q *Queries) RestoreUser(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, restoreUser, id)
+	return err
+}
+
+const softDeleteUser = `-- name: SoftDeleteUser :execrows
+UPDATE users
+SET deleted_at = NOW(), updated_at = NOW()
+WHERE id = $1
+  AND deleted_at IS NULL
+`
+
+func (q *Queries) SoftDeleteUser(ctx context.Context, id uuid.UUID) (int64, error) {
+	result, err := q.db.ExecContext(ctx, softDeleteUser, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
 const updateUser = `-- name: UpdateUser :one
 UPDATE users
-SET email = $1, hashed_password = $2, updated_at = NOW()
-WHERE id = $3
-RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red
+SET email = $1,
+    hashed_password = $2,
+    username = COALESCE($3::text, username),
+    display_name = COALESCE($4::text, display_name),
+    bio = COALESCE($5::text, bio),
+    location = COALESCE($6::text, location),
+    email_verified = email_verified AND email = $1,
+    updated_at = NOW()
+WHERE id = $7
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, username, display_name, bio, location, email_verified, deleted_at, is_admin
 `
 
 type UpdateUserParams struct {
 	Email          string
 	HashedPassword string
+	Username       sql.NullString
+	DisplayName    sql.NullString
+	Bio            sql.NullString
+	Location       sql.NullString
 	ID             uuid.UUID
 }
 
+// Profile fields left NULL keep their current value. A new email address
+// has to be verified again.
 func (q *Queries) UpdateUser(ctx context.Context, arg UpdateUserParams) (User, error) {
-	row := q.db.QueryRowContext(ctx, updateUser, arg.Email, arg.HashedPassword, arg.ID)
+	row := q.db.QueryRowContext(ctx, updateUser,
+		arg.Email,
+		arg.HashedPassword,
+		arg.Username,
+		arg.DisplayName,
+		arg.Bio,
+		arg.Location,
+		arg.ID,
+	)
 	var i User
 	err := row.Scan(
 		&i.ID,
@@ -127,15 +508,38 @@ func (q *Queries) UpdateUser(ctx context.Context, arg UpdateUserParams) (User, e
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.Username,
+		&i.DisplayName,
+		&i.Bio,
+		&i.Location,
+		&i.EmailVerified,
+		&i.DeletedAt,
+		&i.IsAdmin,
 	)
 	return i, err
 }
 
+const updateUserPassword = `-- name: UpdateUserPassword :exec
+UPDATE users
+SET hashed_password = $1, updated_at = NOW()
+WHERE id = $2
+`
+
+type UpdateUserPasswordParams struct {
+	HashedPassword string
+	ID             uuid.UUID
+}
+
+func (q *Queries) UpdateUserPassword(ctx context.Context, arg UpdateUserPasswordParams) error {
+	_, err := q.db.ExecContext(ctx, updateUserPassword, arg.HashedPassword, arg.ID)
+	return err
+}
+
 const upgradeUserToRed = `-- name: UpgradeUserToRed :one
 UPDATE users
 SET is_chirpy_red = true, updated_at = Now()
 WHERE id = $1
-RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, username, display_name, bio, location, email_verified, deleted_at, is_admin
 `
 
 func (q *Queries) UpgradeUserToRed(ctx context.Context, id uuid.UUID) (User, error) {
@@ -148,6 +552,13 @@ func (q *Queries) UpgradeUserToRed(ctx context.Context, id uuid.UUID) (User, err
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.Username,
+		&i.DisplayName,
+		&i.Bio,
+		&i.Location,
+		&i.EmailVerified,
+		&i.DeletedAt,
+		&i.IsAdmin,
 	)
 	return i, err
 }
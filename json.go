@@ -32,3 +32,23 @@ func respondWithJSON(writer http.ResponseWriter, code int, payload interface{})
 	writer.WriteHeader(code)
 	writer.Write(dat)
 }
+
+// fieldError says why one field of a request was rejected.
+type fieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// respondWithFieldErrors is a 400 that also lists each problem by field,
+// so a client can show them next to the right inputs.
+func respondWithFieldErrors(writer http.ResponseWriter, msg string, fields []fieldError) {
+	type errorResponse struct {
+		Error  string       `json:"error"`
+		Fields []fieldError `json:"fields"`
+	}
+	respondWithJSON(writer, http.StatusBadRequest, errorResponse{
+		Error:  msg,
+		Fields: fields,
+	})
+}
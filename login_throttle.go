@@ -0,0 +1,179 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/tomanta/chirpy/internal/auth"
+	"github.com/tomanta/chirpy/internal/database"
+	"github.com/tomanta/chirpy/internal/mail"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// loginFailureWindow is how long a failure counts towards the next
+	// delay. A quiet spell this long starts the count over.
+	loginFailureWindow        = 24 * time.Hour
+	loginFailurePruneInterval = time.Hour
+)
+
+// accountLoginThrottle slows down guessing against one account, whichever
+// addresses it comes from, and locks the account after ten failures.
+var accountLoginThrottle = auth.LoginThrottle{
+	FreeAttempts:    3,
+	BaseDelay:       time.Second,
+	MaxDelay:        5 * time.Minute,
+	LockoutAttempts: 10,
+	LockoutDuration: 30 * time.Minute,
+}
+
+// ipLoginThrottle slows down one address spraying guesses across many
+// accounts. It's looser, since many users can share an address.
+var ipLoginThrottle = auth.LoginThrottle{
+	FreeAttempts: 20,
+	BaseDelay:    time.Second,
+	MaxDelay:     15 * time.Minute,
+}
+
+// Failures are tracked by the email as typed rather than by user, so an
+// address without an account is throttled exactly like one with.
+func emailLoginKey(email string) string {
+	return "email:" + strings.ToLower(strings.TrimSpace(email))
+}
+
+func twoFactorLoginKey(userID uuid.UUID) string {
+	return "2fa:" + userID.String()
+}
+
+func ipLoginKey(request *http.Request) string {
+	return "ip:" + clientIP(request)
+}
+
+// checkLoginLock responds with a 429 and returns false if the account key
+// or the client's address is locked out.
+func (cfg *apiConfig) checkLoginLock(writer http.ResponseWriter, request *http.Request, accountKey string) bool {
+	return cfg.checkThrottle(writer, "Too many failed login attempts, try again later", accountKey, ipLoginKey(request))
+}
+
+// checkThrottle responds with a 429 carrying msg and returns false if any
+// of keys is locked.
+func (cfg *apiConfig) checkThrottle(writer http.ResponseWriter, msg string, keys ...string) bool {
+	lockedUntil, err := cfg.dbQueries.GetLoginLockout(context.Background(), keys)
+	if errors.Is(err, sql.ErrNoRows) {
+		return true
+	}
+	if err != nil {
+		respondWithError(writer, http.StatusInternalServerError, "Couldn't check recent attempts", err)
+		return false
+	}
+
+	retryAfter := max(1, int(math.Ceil(time.Until(lockedUntil.Time).Seconds())))
+	writer.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+	respondWithError(writer, http.StatusTooManyRequests, msg, nil)
+	return false
+}
+
+// loginFailed counts a failed attempt against the account key and the
+// client's address. When the account reaches the lockout threshold its
+// owner, if it has one, is told by email.
+func (cfg *apiConfig) loginFailed(request *http.Request, accountKey string, userID uuid.NullUUID) {
+	ctx := context.Background()
+
+	failures, err := cfg.recordLoginFailure(ctx, accountKey, accountLoginThrottle)
+	if err != nil {
+		log.Printf("Couldn't record failed login: %s", err)
+	} else if failures == accountLoginThrottle.LockoutAttempts && userID.Valid {
+		cfg.recordAuditEvent(ctx, request, auditAccountLocked, userID, uuid.NullUUID{})
+		go func() {
+			err := cfg.sendAccountLockedEmail(context.Background(), userID.UUID, failures)
+			if err != nil {
+				log.Printf("Couldn't send account locked email: %s", err)
+			}
+		}()
+	}
+
+	_, err = cfg.recordLoginFailure(ctx, ipLoginKey(request), ipLoginThrottle)
+	if err != nil {
+		log.Printf("Couldn't record failed login: %s", err)
+	}
+}
+
+// recordLoginFailure bumps the count for key, locks it for as long as
+// throttle says, and returns the new count. Anything else that needs
+// throttling counts its attempts the same way under its own keys.
+func (cfg *apiConfig) recordLoginFailure(ctx context.Context, key string, throttle auth.LoginThrottle) (int, error) {
+	now := time.Now().UTC()
+
+	failures, err := cfg.dbQueries.RecordLoginFailure(ctx, database.RecordLoginFailureParams{
+		Key:         key,
+		WindowStart: now.Add(-loginFailureWindow),
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	delay := throttle.Delay(int(failures))
+	if delay > 0 {
+		err = cfg.dbQueries.LockLogin(ctx, database.LockLoginParams{
+			LockedUntil: now.Add(delay),
+			Key:         key,
+		})
+		if err != nil {
+			return 0, err
+		}
+	}
+	return int(failures), nil
+}
+
+// clearLoginFailures forgets earlier failures after a successful login.
+// The client's address keeps its count, so one good account can't be used
+// to reset it.
+func (cfg *apiConfig) clearLoginFailures(ctx context.Context, user database.User) {
+	for _, key := range []string{emailLoginKey(user.Email), twoFactorLoginKey(user.ID)} {
+		err := cfg.dbQueries.ClearLoginFailures(ctx, key)
+		if err != nil {
+			log.Printf("Couldn't clear failed logins: %s", err)
+		}
+	}
+}
+
+func (cfg *apiConfig) sendAccountLockedEmail(ctx context.Context, userID uuid.UUID, failures int) error {
+	user, err := cfg.dbQueries.GetUserByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	return cfg.mailer.Send(ctx, mail.Message{
+		To:      user.Email,
+		Subject: "Your Chirpy account has been locked",
+		Body: fmt.Sprintf("There were %d failed attempts to log in to your account, so logins are paused for %s.\n\nIf this was you, wait and try again. If it wasn't, someone may be guessing your password; consider changing it once you're back in.\n",
+			failures, accountLoginThrottle.LockoutDuration),
+	})
+}
+
+// pruneLoginFailures drops failure counts that have gone quiet, once
+// immediately and then every interval until ctx is done.
+func (cfg *apiConfig) pruneLoginFailures(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		_, err := cfg.dbQueries.DeleteStaleLoginFailures(ctx, time.Now().UTC().Add(-loginFailureWindow))
+		if err != nil {
+			log.Printf("Couldn't prune failed logins: %s", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -1,23 +1,41 @@
 package main
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"github.com/joho/godotenv"
 	_ "github.com/lib/pq"
+	"github.com/tomanta/chirpy/internal/auth"
 	"github.com/tomanta/chirpy/internal/database"
+	"github.com/tomanta/chirpy/internal/mail"
 	"log"
+	"net"
 	"net/http"
+	"net/smtp"
 	"os"
+	"strconv"
+	"strings"
 	"sync/atomic"
+	"time"
 )
 
 type apiConfig struct {
 	fileserverHits atomic.Int32
+	db             *sql.DB
 	dbQueries      *database.Queries
 	platform       string
-	jwtSecret      string
+	jwtKeys        *auth.Keyring
 	polkaKey       string
+	mailer         mail.Mailer
+	passwordPolicy auth.PasswordPolicy
+	// requireVerifiedEmail stops users from chirping until they verify
+	// their email address.
+	requireVerifiedEmail bool
+	// accountDeletionGrace is how long a deleted account can still be
+	// restored by logging in.
+	accountDeletionGrace time.Duration
 }
 
 func main() {
@@ -29,8 +47,7 @@ func main() {
 	dbURL := os.Getenv("DB_URL")
 	db, err := sql.Open("postgres", dbURL)
 	if err != nil {
-		fmt.Errorf("Could not create db: %w", err)
-		os.Exit(1)
+		log.Fatalf("Could not create db: %s", err)
 	}
 
 	platform := os.Getenv("PLATFORM")
@@ -38,9 +55,9 @@ func main() {
 		log.Fatal("PLATFORM must be set in .ENV")
 	}
 
-	jwtSecret := os.Getenv("JWT_SECRET")
-	if jwtSecret == "" {
-		log.Fatal("JWT_SECRET must be set in .ENV")
+	jwtKeys, err := jwtKeysFromEnv()
+	if err != nil {
+		log.Fatalf("Could not load JWT keys: %s", err)
 	}
 
 	polkaKey := os.Getenv("POLKA_KEY")
@@ -48,28 +65,101 @@ func main() {
 		log.Fatal("POLKA_KEY must be set in .ENV")
 	}
 
+	mailer, err := mailerFromEnv()
+	if err != nil {
+		log.Fatalf("Could not configure mailer: %s", err)
+	}
+
+	passwordPolicy, err := passwordPolicyFromEnv()
+	if err != nil {
+		log.Fatalf("Could not configure password policy: %s", err)
+	}
+
+	requireVerifiedEmail := false
+	if value := os.Getenv("REQUIRE_VERIFIED_EMAIL"); value != "" {
+		requireVerifiedEmail, err = strconv.ParseBool(value)
+		if err != nil {
+			log.Fatalf("REQUIRE_VERIFIED_EMAIL must be true or false: %s", err)
+		}
+	}
+
+	accountDeletionGrace := defaultAccountDeletionGrace
+	if value := os.Getenv("ACCOUNT_DELETION_GRACE_PERIOD"); value != "" {
+		accountDeletionGrace, err = time.ParseDuration(value)
+		if err != nil {
+			log.Fatalf("ACCOUNT_DELETION_GRACE_PERIOD must be a duration: %s", err)
+		}
+	}
+
 	cfg := apiConfig{
 		fileserverHits: atomic.Int32{},
+		db:             db,
 		dbQueries:      database.New(db),
 		platform:       platform,
-		jwtSecret:      jwtSecret,
+		jwtKeys:        jwtKeys,
 		polkaKey:       polkaKey,
+		mailer:         mailer,
+		passwordPolicy: passwordPolicy,
+
+		requireVerifiedEmail: requireVerifiedEmail,
+		accountDeletionGrace: accountDeletionGrace,
 	}
 
+	go cfg.purgeDeletedAccounts(context.Background(), accountPurgeInterval)
+	go cfg.processExportJobs(context.Background(), exportWorkerInterval)
+	go cfg.pruneLoginFailures(context.Background(), loginFailurePruneInterval)
+
 	serveMux := http.NewServeMux()
 	serveMux.Handle("/app/", cfg.middlewareMetricsInc(http.StripPrefix("/app", http.FileServer(http.Dir(filepathRoot)))))
 
 	serveMux.HandleFunc("GET /api/healthz", handlerHealthz)
+	serveMux.HandleFunc("GET /.well-known/jwks.json", cfg.handlerJWKS)
 	serveMux.HandleFunc("GET /api/chirps", cfg.handlerGetChirps)
+	serveMux.HandleFunc("GET /api/chirps/search", cfg.handlerSearchChirps)
 	serveMux.HandleFunc("GET /api/chirps/{chirpID}", cfg.handlerGetChirpByID)
+	serveMux.HandleFunc("GET /api/chirps/{chirpID}/replies", cfg.handlerGetChirpReplies)
+	serveMux.HandleFunc("GET /api/chirps/{chirpID}/thread", cfg.handlerGetChirpThread)
+	serveMux.HandleFunc("POST /api/chirps/{chirpID}/like", cfg.handlerLikeChirp)
+	serveMux.HandleFunc("DELETE /api/chirps/{chirpID}/like", cfg.handlerUnlikeChirp)
 	serveMux.HandleFunc("DELETE /api/chirps/{chirpID}", cfg.handlerDeleteChirpByID)
+	serveMux.HandleFunc("PUT /api/chirps/{chirpID}", cfg.handlerUpdateChirp)
+	serveMux.HandleFunc("GET /api/chirps/{chirpID}/revisions", cfg.handlerGetChirpRevisions)
 	serveMux.HandleFunc("POST /api/chirps", cfg.handlerCreateChirp)
+	serveMux.HandleFunc("GET /api/hashtags/trending", cfg.handlerGetTrendingHashtags)
+	serveMux.HandleFunc("GET /api/hashtags/{tag}/chirps", cfg.handlerGetHashtagChirps)
 	serveMux.HandleFunc("POST /api/users", cfg.handlerCreateUser)
 	serveMux.HandleFunc("PUT /api/users", cfg.handlerUpdateUser)
+	serveMux.HandleFunc("PATCH /api/users", cfg.handlerPatchUser)
+	serveMux.HandleFunc("DELETE /api/users", cfg.handlerDeleteUser)
+	serveMux.HandleFunc("POST /api/users/verify", cfg.handlerVerifyEmail)
+	serveMux.HandleFunc("POST /api/users/verify/resend", cfg.handlerResendVerification)
+	serveMux.HandleFunc("POST /api/users/2fa", cfg.handlerEnrollTwoFactor)
+	serveMux.HandleFunc("POST /api/users/2fa/confirm", cfg.handlerConfirmTwoFactor)
+	serveMux.HandleFunc("DELETE /api/users/2fa", cfg.handlerDisableTwoFactor)
+	serveMux.HandleFunc("GET /api/users/me/export", cfg.handlerExportUser)
+	serveMux.HandleFunc("GET /api/users/me/export/{jobID}", cfg.handlerGetExportJob)
+	serveMux.HandleFunc("GET /api/users/me/export/{jobID}/archive", cfg.handlerDownloadExport)
+	serveMux.HandleFunc("GET /api/users/{username}", cfg.handlerGetProfile)
+	serveMux.HandleFunc("POST /api/users/{userID}/follow", cfg.handlerFollowUser)
+	serveMux.HandleFunc("DELETE /api/users/{userID}/follow", cfg.handlerUnfollowUser)
+	serveMux.HandleFunc("GET /api/users/{userID}/followers", cfg.handlerGetFollowers)
+	serveMux.HandleFunc("GET /api/users/{userID}/following", cfg.handlerGetFollowing)
+	serveMux.HandleFunc("GET /api/timeline", cfg.handlerGetTimeline)
+	serveMux.HandleFunc("GET /api/mentions", cfg.handlerGetMentions)
+	serveMux.HandleFunc("POST /api/mentions/read", cfg.handlerMarkMentionsRead)
 	serveMux.HandleFunc("POST /api/polka/webhooks", cfg.handlerUpgradeRed)
 	serveMux.HandleFunc("POST /api/login", cfg.handlerUserLogin)
+	serveMux.HandleFunc("POST /api/login/2fa", cfg.handlerLoginTwoFactor)
 	serveMux.HandleFunc("POST /api/refresh", cfg.handlerRefresh)
 	serveMux.HandleFunc("POST /api/revoke", cfg.handlerRevoke)
+	serveMux.HandleFunc("GET /api/sessions", cfg.handlerGetSessions)
+	serveMux.HandleFunc("DELETE /api/sessions/{sessionID}", cfg.handlerRevokeSession)
+	serveMux.HandleFunc("POST /api/sessions/revoke-all", cfg.handlerRevokeAllSessions)
+	serveMux.HandleFunc("POST /api/tokens", cfg.handlerCreateToken)
+	serveMux.HandleFunc("GET /api/tokens", cfg.handlerGetTokens)
+	serveMux.HandleFunc("DELETE /api/tokens/{tokenID}", cfg.handlerRevokeToken)
+	serveMux.HandleFunc("POST /api/password/forgot", cfg.handlerForgotPassword)
+	serveMux.HandleFunc("POST /api/password/reset", cfg.handlerResetPassword)
 	serveMux.HandleFunc("GET /admin/metrics", cfg.handlerMetrics)
 	serveMux.HandleFunc("POST /admin/reset", cfg.handlerReset)
 
@@ -81,3 +171,127 @@ func main() {
 	log.Printf("Serving files from %s on port: %s\n", filepathRoot, port)
 	log.Fatal(server.ListenAndServe())
 }
+
+// jwtKeysFromEnv loads the keys that sign and verify JWTs. JWT_SIGNING_KEYS
+// is a comma-separated list of kid=path entries pointing at PEM private
+// keys; the first signs new tokens and the rest only verify, so a new key
+// can be rolled in before the old one is dropped. JWT_SECRET is the
+// original HS256 secret: it signs when there are no other keys, and
+// otherwise only verifies tokens issued before the switch.
+func jwtKeysFromEnv() (*auth.Keyring, error) {
+	keys := []auth.SigningKey{}
+	if value := os.Getenv("JWT_SIGNING_KEYS"); value != "" {
+		for _, entry := range strings.Split(value, ",") {
+			id, path, ok := strings.Cut(strings.TrimSpace(entry), "=")
+			if !ok || id == "" || path == "" {
+				return nil, fmt.Errorf("JWT_SIGNING_KEYS entry %q must be kid=path", entry)
+			}
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return nil, err
+			}
+			key, err := auth.ParsePrivateKeyPEM(id, data)
+			if err != nil {
+				return nil, err
+			}
+			keys = append(keys, key)
+		}
+	}
+
+	if secret := os.Getenv("JWT_SECRET"); secret != "" {
+		keys = append(keys, auth.NewHMACKey("", []byte(secret)))
+	}
+
+	if len(keys) == 0 {
+		return nil, errors.New("JWT_SECRET or JWT_SIGNING_KEYS must be set in .ENV")
+	}
+	return auth.NewKeyring(keys[0], keys[1:]...)
+}
+
+// mailerFromEnv picks the mail transport from MAILER: "smtp", "file" (which
+// writes messages to MAIL_DIR) or "memory". There's no default, so a
+// deployment can't end up quietly keeping reset links on disk.
+func mailerFromEnv() (mail.Mailer, error) {
+	from := os.Getenv("MAIL_FROM")
+	if from == "" {
+		from = "Chirpy <no-reply@chirpy.local>"
+	}
+
+	switch os.Getenv("MAILER") {
+	case "":
+		return nil, errors.New("MAILER must be set in .ENV")
+	case "file":
+		dir := os.Getenv("MAIL_DIR")
+		if dir == "" {
+			dir = "mail"
+		}
+		return &mail.FileMailer{Dir: dir, From: from}, nil
+	case "memory":
+		return &mail.MemoryMailer{}, nil
+	case "smtp":
+		addr := os.Getenv("SMTP_ADDR")
+		host, _, err := net.SplitHostPort(addr)
+		if err != nil {
+			return nil, err
+		}
+		mailer := &mail.SMTPMailer{Addr: addr, From: from}
+		if username := os.Getenv("SMTP_USERNAME"); username != "" {
+			mailer.Auth = smtp.PlainAuth("", username, os.Getenv("SMTP_PASSWORD"), host)
+		}
+		return mailer, nil
+	default:
+		return nil, errors.New("MAILER must be smtp, file or memory")
+	}
+}
+
+// passwordPolicyFromEnv starts from the default policy. PASSWORD_MIN_LENGTH
+// overrides the length, PASSWORD_REQUIRE lists the character classes a
+// password needs ("uppercase,lowercase,digit,symbol", or "none"), and
+// BREACHED_PASSWORDS_DIR points at a directory of Pwned Passwords range
+// files to check new passwords against.
+func passwordPolicyFromEnv() (auth.PasswordPolicy, error) {
+	policy := auth.DefaultPasswordPolicy
+
+	if value := os.Getenv("PASSWORD_MIN_LENGTH"); value != "" {
+		minLength, err := strconv.Atoi(value)
+		if err != nil || minLength < 1 {
+			return policy, errors.New("PASSWORD_MIN_LENGTH must be a positive number")
+		}
+		policy.MinLength = minLength
+	}
+
+	if value := os.Getenv("PASSWORD_REQUIRE"); value != "" {
+		policy.RequireUppercase = false
+		policy.RequireLowercase = false
+		policy.RequireDigit = false
+		policy.RequireSymbol = false
+		for _, class := range strings.Split(value, ",") {
+			switch strings.TrimSpace(class) {
+			case "none":
+			case "uppercase":
+				policy.RequireUppercase = true
+			case "lowercase":
+				policy.RequireLowercase = true
+			case "digit":
+				policy.RequireDigit = true
+			case "symbol":
+				policy.RequireSymbol = true
+			default:
+				return policy, fmt.Errorf("PASSWORD_REQUIRE: unknown character class %q", class)
+			}
+		}
+	}
+
+	if dir := os.Getenv("BREACHED_PASSWORDS_DIR"); dir != "" {
+		info, err := os.Stat(dir)
+		if err != nil {
+			return policy, err
+		}
+		if !info.IsDir() {
+			return policy, fmt.Errorf("BREACHED_PASSWORDS_DIR: %s is not a directory", dir)
+		}
+		policy.Breached = auth.NewBreachedPasswords(os.DirFS(dir))
+	}
+
+	return policy, nil
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"github.com/tomanta/chirpy/internal/database"
+	"regexp"
+	"strings"
+)
+
+const maxMentionsPerChirp = 10
+
+// A mention is an @ followed by a username or the characters allowed in the
+// local part of an email address, not glued to the end of another word or
+// address.
+var mentionPattern = regexp.MustCompile(`(?:^|[^\p{L}\p{N}_@.])@([A-Za-z0-9._%+-]+)`)
+
+// extractMentions returns the distinct, lower-cased handles mentioned in
+// body, in the order they first appear.
+func extractMentions(body string) []string {
+	handles := []string{}
+	seen := map[string]struct{}{}
+	for _, match := range mentionPattern.FindAllStringSubmatch(body, -1) {
+		// Sentence punctuation after a handle isn't part of it.
+		handle := strings.ToLower(strings.TrimRight(match[1], ".-"))
+		if handle == "" {
+			continue
+		}
+		if _, ok := seen[handle]; ok {
+			continue
+		}
+		seen[handle] = struct{}{}
+		handles = append(handles, handle)
+		if len(handles) == maxMentionsPerChirp {
+			break
+		}
+	}
+	return handles
+}
+
+// resolveMentions maps handles to user IDs. A handle is matched against
+// usernames first and falls back to the local part of an email address for
+// users who haven't picked one. Handles that match no user, or more than
+// one by email, are dropped.
+func resolveMentions(ctx context.Context, q *database.Queries, handles []string) ([]uuid.UUID, error) {
+	if len(handles) == 0 {
+		return []uuid.UUID{}, nil
+	}
+
+	byUsername, err := q.GetUsersByUsername(ctx, handles)
+	if err != nil {
+		return nil, err
+	}
+
+	matches := map[string][]uuid.UUID{}
+	for _, r := range byUsername {
+		matches[r.Handle] = []uuid.UUID{r.ID}
+	}
+
+	unmatched := []string{}
+	for _, handle := range handles {
+		if _, ok := matches[handle]; !ok {
+			unmatched = append(unmatched, handle)
+		}
+	}
+
+	if len(unmatched) > 0 {
+		byEmail, err := q.GetUsersByEmailLocalPart(ctx, unmatched)
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range byEmail {
+			matches[r.Handle] = append(matches[r.Handle], r.ID)
+		}
+	}
+
+	userIDs := []uuid.UUID{}
+	for _, handle := range handles {
+		if ids := matches[handle]; len(ids) == 1 {
+			userIDs = append(userIDs, ids[0])
+		}
+	}
+	return userIDs, nil
+}
+
+// syncMentions brings the mention records for a chirp in line with its
+// current body. Mentions that survive an edit keep their read state. Like
+// indexHashtags it runs in the transaction that wrote the chirp.
+func syncMentions(ctx context.Context, q *database.Queries, chirp database.Chirp) error {
+	mentioned, err := resolveMentions(ctx, q, extractMentions(chirp.Body))
+	if err != nil {
+		return err
+	}
+
+	// Mentioning yourself doesn't notify anyone.
+	userIDs := []uuid.UUID{}
+	for _, id := range mentioned {
+		if id != chirp.UserID {
+			userIDs = append(userIDs, id)
+		}
+	}
+
+	err = q.PruneChirpMentions(ctx, database.PruneChirpMentionsParams{
+		ChirpID: chirp.ID,
+		UserIds: userIDs,
+	})
+	if err != nil {
+		return err
+	}
+
+	if len(userIDs) == 0 {
+		return nil
+	}
+	return q.AddChirpMentions(ctx, database.AddChirpMentionsParams{
+		ChirpID:   chirp.ID,
+		UserIds:   userIDs,
+		CreatedAt: chirp.CreatedAt,
+	})
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestExtractMentions(t *testing.T) {
+	many := []string{}
+	for i := 0; i < maxMentionsPerChirp+2; i++ {
+		many = append(many, fmt.Sprintf("@user%d", i))
+	}
+
+	tests := []struct {
+		name string
+		body string
+		want []string
+	}{
+		{
+			name: "No mentions",
+			body: "just a chirp",
+			want: []string{},
+		},
+		{
+			name: "Email address",
+			body: "write to a@b.com",
+			want: []string{},
+		},
+		{
+			name: "Mention followed by a domain",
+			body: "@alice@example.com",
+			want: []string{"alice"},
+		},
+		{
+			name: "Double at",
+			body: "@@z",
+			want: []string{},
+		},
+		{
+			name: "Trailing punctuation",
+			body: "hi @bob. @carol, @dave! @erin- (@frank)",
+			want: []string{"bob", "carol", "dave", "erin", "frank"},
+		},
+		{
+			name: "Dots inside a handle",
+			body: "@first.last.",
+			want: []string{"first.last"},
+		},
+		{
+			name: "Case folding and duplicates",
+			body: "@Bob and @bob and @BOB",
+			want: []string{"bob"},
+		},
+		{
+			name: "Capped per chirp",
+			body: strings.Join(many, " "),
+			want: []string{"user0", "user1", "user2", "user3", "user4", "user5", "user6", "user7", "user8", "user9"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := extractMentions(tt.body); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("extractMentions(%q) = %v, want %v", tt.body, got, tt.want)
+			}
+		})
+	}
+}
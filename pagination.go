@@ -0,0 +1,161 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"github.com/google/uuid"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultPageLimit = 20
+	maxPageLimit     = 100
+)
+
+type cursorDirection string
+
+const (
+	cursorNext cursorDirection = "next"
+	cursorPrev cursorDirection = "prev"
+)
+
+// pageCursor marks a position in a (created_at, id) ordered listing, or a
+// (rank, created_at, id) ordered one for search results. It is handed to
+// clients as an opaque base64 string.
+type pageCursor struct {
+	Rank      float32         `json:"r,omitempty"`
+	CreatedAt time.Time       `json:"t"`
+	ID        uuid.UUID       `json:"id"`
+	Direction cursorDirection `json:"d"`
+}
+
+func encodeCursor(c pageCursor) string {
+	dat, err := json.Marshal(c)
+	if err != nil {
+		return ""
+	}
+	return base64.RawURLEncoding.EncodeToString(dat)
+}
+
+func decodeCursor(s string) (pageCursor, error) {
+	c := pageCursor{}
+	dat, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return c, errors.New("malformed cursor")
+	}
+	err = json.Unmarshal(dat, &c)
+	if err != nil {
+		return c, errors.New("malformed cursor")
+	}
+	if c.Direction != cursorNext && c.Direction != cursorPrev {
+		return c, errors.New("malformed cursor")
+	}
+	return c, nil
+}
+
+type pageRequest struct {
+	Cursor    *pageCursor
+	Limit     int
+	Ascending bool
+}
+
+// parsePageRequest reads the cursor, limit and sort query parameters shared
+// by every paginated listing.
+func parsePageRequest(query url.Values, defaultAscending bool) (pageRequest, error) {
+	page := pageRequest{
+		Limit:     defaultPageLimit,
+		Ascending: defaultAscending,
+	}
+
+	switch query.Get("sort") {
+	case "":
+	case "asc":
+		page.Ascending = true
+	case "desc":
+		page.Ascending = false
+	default:
+		return page, errors.New("Invalid sort order")
+	}
+
+	if limit := query.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 1 {
+			return page, errors.New("Invalid limit")
+		}
+		page.Limit = min(n, maxPageLimit)
+	}
+
+	if cursor := query.Get("cursor"); cursor != "" {
+		c, err := decodeCursor(cursor)
+		if err != nil {
+			return page, errors.New("Invalid cursor")
+		}
+		page.Cursor = &c
+	}
+
+	return page, nil
+}
+
+// scanForward reports whether the rows for this page have to be fetched in
+// ascending (created_at, id) order.
+func (page pageRequest) scanForward() bool {
+	backward := page.Cursor != nil && page.Cursor.Direction == cursorPrev
+	return page.Ascending != backward
+}
+
+// cursorParams converts the cursor into the nullable query arguments used by
+// the keyset listing queries.
+func (page pageRequest) cursorParams() (sql.NullTime, uuid.NullUUID) {
+	if page.Cursor == nil {
+		return sql.NullTime{}, uuid.NullUUID{}
+	}
+	return sql.NullTime{Time: page.Cursor.CreatedAt, Valid: true}, uuid.NullUUID{UUID: page.Cursor.ID, Valid: true}
+}
+
+// cursorRank is the rank argument for search queries.
+func (page pageRequest) cursorRank() sql.NullFloat64 {
+	if page.Cursor == nil {
+		return sql.NullFloat64{}
+	}
+	return sql.NullFloat64{Float64: float64(page.Cursor.Rank), Valid: true}
+}
+
+// pageCursors trims a result set that was fetched with limit+1 rows, restores
+// display order and works out the cursors for the neighbouring pages. key
+// returns the position of a row; its Direction is filled in here.
+func pageCursors[T any](page pageRequest, rows []T, key func(T) pageCursor) ([]T, string, string) {
+	hasMore := len(rows) > page.Limit
+	if hasMore {
+		rows = rows[:page.Limit]
+	}
+
+	backward := page.Cursor != nil && page.Cursor.Direction == cursorPrev
+	if backward {
+		for i, j := 0, len(rows)-1; i < j; i, j = i+1, j-1 {
+			rows[i], rows[j] = rows[j], rows[i]
+		}
+	}
+
+	if len(rows) == 0 {
+		return rows, "", ""
+	}
+
+	cursorAt := func(row T, direction cursorDirection) string {
+		c := key(row)
+		c.Direction = direction
+		return encodeCursor(c)
+	}
+
+	nextCursor, prevCursor := "", ""
+	if backward || hasMore {
+		nextCursor = cursorAt(rows[len(rows)-1], cursorNext)
+	}
+	if (backward && hasMore) || (!backward && page.Cursor != nil) {
+		prevCursor = cursorAt(rows[0], cursorPrev)
+	}
+	return rows, nextCursor, prevCursor
+}
@@ -0,0 +1,283 @@
+package main
+
+import (
+	"bytes"
+	"github.com/google/uuid"
+	"net/url"
+	"reflect"
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// testRow stands in for a chirp in a (created_at, id) ordered listing.
+type testRow struct {
+	CreatedAt time.Time
+	ID        uuid.UUID
+}
+
+func testRowKey(r testRow) pageCursor {
+	return pageCursor{CreatedAt: r.CreatedAt, ID: r.ID}
+}
+
+func compareTestRows(a, b testRow) int {
+	if c := a.CreatedAt.Compare(b.CreatedAt); c != 0 {
+		return c
+	}
+	return bytes.Compare(a.ID[:], b.ID[:])
+}
+
+// testRows returns n rows in ascending order. Pairs of rows share a
+// timestamp so the id tie-break gets exercised.
+func testRows(n int) []testRow {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	rows := []testRow{}
+	for i := 0; i < n; i++ {
+		rows = append(rows, testRow{
+			CreatedAt: start.Add(time.Duration(i/2) * time.Minute),
+			ID:        uuid.UUID{15: byte(i)},
+		})
+	}
+	return rows
+}
+
+// fetchTestPage does what the keyset listing queries do: rows past the
+// cursor in scan order, limit+1 of them.
+func fetchTestPage(all []testRow, page pageRequest) []testRow {
+	rows := slices.Clone(all)
+	if !page.scanForward() {
+		slices.Reverse(rows)
+	}
+
+	fetched := []testRow{}
+	for _, r := range rows {
+		if page.Cursor != nil {
+			c := compareTestRows(r, testRow{CreatedAt: page.Cursor.CreatedAt, ID: page.Cursor.ID})
+			if (page.scanForward() && c <= 0) || (!page.scanForward() && c >= 0) {
+				continue
+			}
+		}
+		fetched = append(fetched, r)
+		if len(fetched) == page.Limit+1 {
+			break
+		}
+	}
+	return fetched
+}
+
+func parseTestPage(t *testing.T, sort string, limit int, cursor string) pageRequest {
+	t.Helper()
+	query := url.Values{}
+	query.Set("sort", sort)
+	query.Set("limit", strconv.Itoa(limit))
+	if cursor != "" {
+		query.Set("cursor", cursor)
+	}
+	page, err := parsePageRequest(query, true)
+	if err != nil {
+		t.Fatalf("parsePageRequest() error = %v", err)
+	}
+	return page
+}
+
+func TestPageCursorsWalk(t *testing.T) {
+	tests := []struct {
+		name  string
+		sort  string
+		total int
+		limit int
+	}{
+		{name: "Ascending, partial last page", sort: "asc", total: 10, limit: 3},
+		{name: "Descending, partial last page", sort: "desc", total: 10, limit: 3},
+		{name: "Ascending, exact pages", sort: "asc", total: 10, limit: 5},
+		{name: "Descending, exact pages", sort: "desc", total: 10, limit: 5},
+		{name: "Single page", sort: "asc", total: 4, limit: 20},
+		{name: "Empty", sort: "desc", total: 0, limit: 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			all := testRows(tt.total)
+			want := slices.Clone(all)
+			if tt.sort == "desc" {
+				slices.Reverse(want)
+			}
+
+			// Forward with next cursors from the first page.
+			got := []testRow{}
+			cursor, lastPrev := "", ""
+			for pages := 0; ; pages++ {
+				if pages > tt.total+1 {
+					t.Fatal("next cursors never ran out")
+				}
+				page := parseTestPage(t, tt.sort, tt.limit, cursor)
+				rows, next, prev := pageCursors(page, fetchTestPage(all, page), testRowKey)
+				if pages == 0 && prev != "" {
+					t.Errorf("first page has a prev cursor")
+				}
+				got = append(got, rows...)
+				lastPrev = prev
+				if next == "" {
+					break
+				}
+				cursor = next
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Fatalf("walking next = %v, want %v", got, want)
+			}
+
+			// And back again with prev cursors from the last page.
+			if len(want) <= tt.limit {
+				if lastPrev != "" {
+					t.Errorf("only page has a prev cursor")
+				}
+				return
+			}
+			lastPage := want[len(want)-((len(want)-1)%tt.limit+1):]
+			got = slices.Clone(lastPage)
+			cursor = lastPrev
+			for pages := 0; cursor != ""; pages++ {
+				if pages > tt.total+1 {
+					t.Fatal("prev cursors never ran out")
+				}
+				page := parseTestPage(t, tt.sort, tt.limit, cursor)
+				rows, next, prev := pageCursors(page, fetchTestPage(all, page), testRowKey)
+				if next == "" {
+					t.Errorf("page reached by prev has no next cursor")
+				}
+				got = append(slices.Clone(rows), got...)
+				cursor = prev
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("walking prev = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestDecodeCursor(t *testing.T) {
+	valid := pageCursor{
+		CreatedAt: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+		ID:        uuid.UUID{15: 1},
+		Direction: cursorPrev,
+	}
+
+	tests := []struct {
+		name    string
+		cursor  string
+		want    pageCursor
+		wantErr bool
+	}{
+		{
+			name:   "Round trip",
+			cursor: encodeCursor(valid),
+			want:   valid,
+		},
+		{
+			name:    "Not base64",
+			cursor:  "not a cursor!",
+			wantErr: true,
+		},
+		{
+			name:    "Not JSON",
+			cursor:  "bm90IGpzb24",
+			wantErr: true,
+		},
+		{
+			name:    "Missing direction",
+			cursor:  encodeCursor(pageCursor{CreatedAt: valid.CreatedAt, ID: valid.ID}),
+			wantErr: true,
+		},
+		{
+			name:    "Unknown direction",
+			cursor:  encodeCursor(pageCursor{CreatedAt: valid.CreatedAt, ID: valid.ID, Direction: "sideways"}),
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decodeCursor(tt.cursor)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("decodeCursor() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("decodeCursor() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParsePageRequest(t *testing.T) {
+	tests := []struct {
+		name          string
+		query         string
+		wantLimit     int
+		wantAscending bool
+		wantErr       bool
+	}{
+		{
+			name:          "Defaults",
+			query:         "",
+			wantLimit:     defaultPageLimit,
+			wantAscending: true,
+		},
+		{
+			name:          "Descending with a limit",
+			query:         "sort=desc&limit=5",
+			wantLimit:     5,
+			wantAscending: false,
+		},
+		{
+			name:          "Limit is capped",
+			query:         "limit=1000",
+			wantLimit:     maxPageLimit,
+			wantAscending: true,
+		},
+		{
+			name:    "Zero limit",
+			query:   "limit=0",
+			wantErr: true,
+		},
+		{
+			name:    "Negative limit",
+			query:   "limit=-3",
+			wantErr: true,
+		},
+		{
+			name:    "Non-numeric limit",
+			query:   "limit=ten",
+			wantErr: true,
+		},
+		{
+			name:    "Unknown sort",
+			query:   "sort=sideways",
+			wantErr: true,
+		},
+		{
+			name:    "Malformed cursor",
+			query:   "cursor=abc",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, err := url.ParseQuery(tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := parsePageRequest(query, true)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parsePageRequest() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got.Limit != tt.wantLimit || got.Ascending != tt.wantAscending {
+				t.Errorf("parsePageRequest() = limit %d, ascending %v; want %d, %v", got.Limit, got.Ascending, tt.wantLimit, tt.wantAscending)
+			}
+		})
+	}
+}
@@ -0,0 +1,5 @@
+-- name: CreateAuditEvent :exec
+INSERT INTO audit_events (id, created_at, event, user_id, session_id, ip, user_agent)
+VALUES (
+    gen_random_uuid(), NOW(), $1, $2, $3, $4, $5
+);
@@ -0,0 +1,67 @@
+-- name: AddChirpMentions :exec
+-- created_at is the chirp's so the inbox follows the chirp timeline.
+INSERT INTO chirp_mentions (chirp_id, user_id, created_at)
+SELECT sqlc.arg('chirp_id')::uuid, unnest(sqlc.arg('user_ids')::uuid[]), sqlc.arg('created_at')::timestamp
+ON CONFLICT (chirp_id, user_id) DO NOTHING;
+
+-- name: PruneChirpMentions :exec
+-- Drops mentions an edit removed, keeping the read state of the rest.
+DELETE FROM chirp_mentions
+WHERE chirp_id = sqlc.arg('chirp_id')::uuid
+  AND NOT (user_id = ANY(sqlc.arg('user_ids')::uuid[]));
+
+-- name: DeleteChirpMentions :exec
+DELETE FROM chirp_mentions
+WHERE chirp_id = $1;
+
+-- name: ListMentionsAsc :many
+SELECT sqlc.embed(c), m.read_at
+FROM chirp_mentions m
+JOIN chirps c ON c.id = m.chirp_id
+WHERE m.user_id = sqlc.arg('user_id')
+  AND c.deleted_at IS NULL
+  AND c.hidden_at IS NULL
+  AND (NOT sqlc.arg('unread_only')::boolean OR m.read_at IS NULL)
+  AND (m.created_at, m.chirp_id) > (
+    COALESCE(sqlc.narg('cursor_created_at')::timestamp, '-infinity'::timestamp),
+    COALESCE(sqlc.narg('cursor_id')::uuid, '00000000-0000-0000-0000-000000000000'::uuid)
+)
+ORDER BY m.created_at ASC, m.chirp_id ASC
+LIMIT sqlc.arg('row_limit');
+
+-- name: ListMentionsDesc :many
+SELECT sqlc.embed(c), m.read_at
+FROM chirp_mentions m
+JOIN chirps c ON c.id = m.chirp_id
+WHERE m.user_id = sqlc.arg('user_id')
+  AND c.deleted_at IS NULL
+  AND c.hidden_at IS NULL
+  AND (NOT sqlc.arg('unread_only')::boolean OR m.read_at IS NULL)
+  AND (m.created_at, m.chirp_id) < (
+    COALESCE(sqlc.narg('cursor_created_at')::timestamp, 'infinity'::timestamp),
+    COALESCE(sqlc.narg('cursor_id')::uuid, 'ffffffff-ffff-ffff-ffff-ffffffffffff'::uuid)
+)
+ORDER BY m.created_at DESC, m.chirp_id DESC
+LIMIT sqlc.arg('row_limit');
+
+-- name: CountUnreadMentions :one
+SELECT COUNT(*)
+FROM chirp_mentions m
+JOIN chirps c ON c.id = m.chirp_id
+WHERE m.user_id = $1
+  AND m.read_at IS NULL
+  AND c.deleted_at IS NULL
+  AND c.hidden_at IS NULL;
+
+-- name: MarkMentionsRead :exec
+UPDATE chirp_mentions
+SET read_at = NOW()
+WHERE user_id = sqlc.arg('user_id')::uuid
+  AND chirp_id = ANY(sqlc.arg('chirp_ids')::uuid[])
+  AND read_at IS NULL;
+
+-- name: MarkAllMentionsRead :exec
+UPDATE chirp_mentions
+SET read_at = NOW()
+WHERE user_id = $1
+  AND read_at IS NULL;
@@ -1,29 +1,264 @@
 -- name: CreateChirp :one
-INSERT INTO chirps(id, created_at, updated_at, body, user_id)
+INSERT INTO chirps(id, created_at, updated_at, body, user_id, in_reply_to, rechirp_of, quote_of)
 VALUES (
-    gen_random_uuid(), NOW(), NOW(), $1, $2
+    gen_random_uuid(), NOW(), NOW(), $1, $2, $3, $4, $5
 )
 RETURNING *;
 
--- name: GetChirps :many
-SELECT id, created_at, updated_at, body, user_id
-FROM chirps
-ORDER BY created_at ASC;
-
 -- name: GetChirpsByAuthor :many
-SELECT id, created_at, updated_at, body, user_id
+SELECT *
 FROM chirps
 WHERE user_id = $1
+  AND deleted_at IS NULL
+  AND hidden_at IS NULL
 ORDER BY created_at ASC;
 
 -- name: GetChirpByID :one
-SELECT id, created_at, updated_at, body, user_id
+SELECT *
 FROM chirps
 WHERE ID = $1;
 
+-- name: GetChirpByIDForUpdate :one
+SELECT *
+FROM chirps
+WHERE id = $1
+FOR UPDATE;
+
+-- name: UpdateChirpBody :one
+UPDATE chirps
+SET body = $1, edited_at = NOW(), updated_at = NOW()
+WHERE id = $2
+RETURNING *;
+
+-- name: GetChirpsByIDs :many
+SELECT *
+FROM chirps
+WHERE id = ANY(sqlc.arg('ids')::uuid[]);
+
+-- name: GetRechirp :one
+SELECT *
+FROM chirps
+WHERE user_id = $1
+  AND rechirp_of = $2;
+
 -- name: ResetChirps :exec
 DELETE FROM chirps;
 
--- name: DeleteChirpByID :exec
+-- name: DeleteChirpByID :execrows
+-- Only removes chirps nobody has replied to or quoted; see TombstoneChirp.
+-- Plain rechirps go with the original through ON DELETE CASCADE.
 DELETE FROM chirps
-WHERE ID = $1;
\ No newline at end of file
+WHERE ID = $1
+  AND NOT EXISTS (SELECT 1 FROM chirps r WHERE r.in_reply_to = $1 OR r.quote_of = $1);
+
+-- name: DeleteRechirpsOf :exec
+DELETE FROM chirps
+WHERE rechirp_of = $1;
+
+-- name: TombstoneChirp :exec
+-- Keeps the row so replies stay attached to their thread.
+UPDATE chirps
+SET body = '', deleted_at = NOW(), updated_at = NOW()
+WHERE id = $1;
+
+-- name: ListChirpsAsc :many
+-- Listing queries are keyset paginated on (created_at, id). A NULL cursor
+-- starts from the beginning (or end) of the range; the COALESCE keeps the
+-- comparison sargable so the composite indexes can serve it.
+SELECT *
+FROM chirps
+WHERE deleted_at IS NULL
+  AND hidden_at IS NULL
+  AND (created_at, id) > (
+    COALESCE(sqlc.narg('cursor_created_at')::timestamp, '-infinity'::timestamp),
+    COALESCE(sqlc.narg('cursor_id')::uuid, '00000000-0000-0000-0000-000000000000'::uuid)
+)
+ORDER BY created_at ASC, id ASC
+LIMIT sqlc.arg('row_limit');
+
+-- name: ListChirpsDesc :many
+SELECT *
+FROM chirps
+WHERE deleted_at IS NULL
+  AND hidden_at IS NULL
+  AND (created_at, id) < (
+    COALESCE(sqlc.narg('cursor_created_at')::timestamp, 'infinity'::timestamp),
+    COALESCE(sqlc.narg('cursor_id')::uuid, 'ffffffff-ffff-ffff-ffff-ffffffffffff'::uuid)
+)
+ORDER BY created_at DESC, id DESC
+LIMIT sqlc.arg('row_limit');
+
+-- name: ListChirpsByAuthorAsc :many
+SELECT *
+FROM chirps
+WHERE user_id = sqlc.arg('author_id')
+  AND deleted_at IS NULL
+  AND hidden_at IS NULL
+  AND (created_at, id) > (
+    COALESCE(sqlc.narg('cursor_created_at')::timestamp, '-infinity'::timestamp),
+    COALESCE(sqlc.narg('cursor_id')::uuid, '00000000-0000-0000-0000-000000000000'::uuid)
+)
+ORDER BY created_at ASC, id ASC
+LIMIT sqlc.arg('row_limit');
+
+-- name: ListChirpsByAuthorDesc :many
+SELECT *
+FROM chirps
+WHERE user_id = sqlc.arg('author_id')
+  AND deleted_at IS NULL
+  AND hidden_at IS NULL
+  AND (created_at, id) < (
+    COALESCE(sqlc.narg('cursor_created_at')::timestamp, 'infinity'::timestamp),
+    COALESCE(sqlc.narg('cursor_id')::uuid, 'ffffffff-ffff-ffff-ffff-ffffffffffff'::uuid)
+)
+ORDER BY created_at DESC, id DESC
+LIMIT sqlc.arg('row_limit');
+
+-- name: ListRepliesAsc :many
+-- Replies include tombstones so a thread keeps its shape.
+SELECT *
+FROM chirps
+WHERE in_reply_to = sqlc.arg('parent_id')::uuid
+  AND (created_at, id) > (
+    COALESCE(sqlc.narg('cursor_created_at')::timestamp, '-infinity'::timestamp),
+    COALESCE(sqlc.narg('cursor_id')::uuid, '00000000-0000-0000-0000-000000000000'::uuid)
+)
+ORDER BY created_at ASC, id ASC
+LIMIT sqlc.arg('row_limit');
+
+-- name: ListRepliesDesc :many
+SELECT *
+FROM chirps
+WHERE in_reply_to = sqlc.arg('parent_id')::uuid
+  AND (created_at, id) < (
+    COALESCE(sqlc.narg('cursor_created_at')::timestamp, 'infinity'::timestamp),
+    COALESCE(sqlc.narg('cursor_id')::uuid, 'ffffffff-ffff-ffff-ffff-ffffffffffff'::uuid)
+)
+ORDER BY created_at DESC, id DESC
+LIMIT sqlc.arg('row_limit');
+
+-- name: GetChirpAncestors :many
+-- Walks in_reply_to up to the root, returned root first.
+WITH RECURSIVE ancestors AS (
+    SELECT parent.*, 1 AS depth
+    FROM chirps parent
+    WHERE parent.id = (SELECT in_reply_to FROM chirps WHERE chirps.id = sqlc.arg('chirp_id')::uuid)
+    UNION ALL
+    SELECT c.*, a.depth + 1
+    FROM chirps c
+    JOIN ancestors a ON c.id = a.in_reply_to
+    WHERE a.depth < sqlc.arg('max_depth')::int
+)
+SELECT c.*
+FROM ancestors a
+JOIN chirps c ON c.id = a.id
+ORDER BY a.depth DESC;
+
+-- name: GetChirpDescendants :many
+-- Every reply below a chirp in creation order, capped at row_limit.
+WITH RECURSIVE descendants AS (
+    SELECT child.*
+    FROM chirps child
+    WHERE child.in_reply_to = sqlc.arg('chirp_id')::uuid
+    UNION ALL
+    SELECT c.*
+    FROM chirps c
+    JOIN descendants d ON c.in_reply_to = d.id
+)
+SELECT *
+FROM descendants
+ORDER BY created_at ASC, id ASC
+LIMIT sqlc.arg('row_limit')::int;
+
+-- name: ListTimelineAsc :many
+-- The home timeline: the user's own chirps and those of everyone they follow.
+SELECT c.*
+FROM chirps c
+WHERE c.deleted_at IS NULL
+  AND c.hidden_at IS NULL
+  AND (c.user_id = sqlc.arg('user_id')::uuid
+       OR c.user_id IN (SELECT followee_id FROM follows WHERE follower_id = sqlc.arg('user_id')::uuid))
+  AND (c.created_at, c.id) > (
+    COALESCE(sqlc.narg('cursor_created_at')::timestamp, '-infinity'::timestamp),
+    COALESCE(sqlc.narg('cursor_id')::uuid, '00000000-0000-0000-0000-000000000000'::uuid)
+)
+ORDER BY c.created_at ASC, c.id ASC
+LIMIT sqlc.arg('row_limit');
+
+-- name: ListTimelineDesc :many
+SELECT c.*
+FROM chirps c
+WHERE c.deleted_at IS NULL
+  AND c.hidden_at IS NULL
+  AND (c.user_id = sqlc.arg('user_id')::uuid
+       OR c.user_id IN (SELECT followee_id FROM follows WHERE follower_id = sqlc.arg('user_id')::uuid))
+  AND (c.created_at, c.id) < (
+    COALESCE(sqlc.narg('cursor_created_at')::timestamp, 'infinity'::timestamp),
+    COALESCE(sqlc.narg('cursor_id')::uuid, 'ffffffff-ffff-ffff-ffff-ffffffffffff'::uuid)
+)
+ORDER BY c.created_at DESC, c.id DESC
+LIMIT sqlc.arg('row_limit');
+
+-- name: SearchChirpsDesc :many
+-- Full-text search, best match first. query accepts websearch syntax, so
+-- "quoted phrases", OR and -exclusions all work. The expression must match
+-- chirps_body_search_idx for the index to be used.
+SELECT c.id, c.created_at, ts_rank(to_tsvector('english', c.body), query)::real AS rank
+FROM chirps c, websearch_to_tsquery('english', sqlc.arg('query')::text) query
+WHERE to_tsvector('english', c.body) @@ query
+  AND c.deleted_at IS NULL
+  AND c.hidden_at IS NULL
+  AND (sqlc.narg('author_id')::uuid IS NULL OR c.user_id = sqlc.narg('author_id'))
+  AND (ts_rank(to_tsvector('english', c.body), query), c.created_at, c.id) < (
+    COALESCE(sqlc.narg('cursor_rank')::real, 'infinity'::real),
+    COALESCE(sqlc.narg('cursor_created_at')::timestamp, 'infinity'::timestamp),
+    COALESCE(sqlc.narg('cursor_id')::uuid, 'ffffffff-ffff-ffff-ffff-ffffffffffff'::uuid)
+)
+ORDER BY rank DESC, c.created_at DESC, c.id DESC
+LIMIT sqlc.arg('row_limit');
+
+-- name: SearchChirpsAsc :many
+SELECT c.id, c.created_at, ts_rank(to_tsvector('english', c.body), query)::real AS rank
+FROM chirps c, websearch_to_tsquery('english', sqlc.arg('query')::text) query
+WHERE to_tsvector('english', c.body) @@ query
+  AND c.deleted_at IS NULL
+  AND c.hidden_at IS NULL
+  AND (sqlc.narg('author_id')::uuid IS NULL OR c.user_id = sqlc.narg('author_id'))
+  AND (ts_rank(to_tsvector('english', c.body), query), c.created_at, c.id) > (
+    COALESCE(sqlc.narg('cursor_rank')::real, '-infinity'::real),
+    COALESCE(sqlc.narg('cursor_created_at')::timestamp, '-infinity'::timestamp),
+    COALESCE(sqlc.narg('cursor_id')::uuid, '00000000-0000-0000-0000-000000000000'::uuid)
+)
+ORDER BY rank ASC, c.created_at ASC, c.id ASC
+LIMIT sqlc.arg('row_limit');
+
+-- name: HideUserChirps :exec
+-- Hides an account's chirps while it waits out the deletion grace period.
+UPDATE chirps
+SET hidden_at = NOW()
+WHERE user_id = $1
+  AND hidden_at IS NULL;
+
+-- name: UnhideUserChirps :exec
+UPDATE chirps
+SET hidden_at = NULL
+WHERE user_id = $1;
+
+-- name: ListUserChirpsWithResponses :many
+-- The user's chirps that someone else's reply or quote points at.
+SELECT c.id
+FROM chirps c
+WHERE c.user_id = $1
+  AND EXISTS (
+    SELECT 1
+    FROM chirps r
+    WHERE (r.in_reply_to = c.id OR r.quote_of = c.id)
+      AND r.user_id <> c.user_id
+  );
+
+-- name: ReassignChirp :exec
+-- Moves a tombstoned chirp to another owner. rechirp_of is dropped so the
+-- new owner can't end up with two rechirps of the same chirp.
+UPDATE chirps
+SET user_id = sqlc.arg('user_id'), rechirp_of = NULL
+WHERE id = sqlc.arg('id');
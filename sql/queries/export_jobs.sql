@@ -0,0 +1,130 @@
+-- name: CreateExportJob :one
+INSERT INTO export_jobs (id, user_id, status, created_at)
+VALUES (gen_random_uuid(), $1, 'pending', NOW())
+RETURNING id, user_id, status, created_at, started_at, completed_at, expires_at, error;
+
+-- name: GetActiveExportJob :one
+-- The user's newest job that hasn't finished yet.
+SELECT id, user_id, status, created_at, started_at, completed_at, expires_at, error
+FROM export_jobs
+WHERE user_id = $1
+  AND status IN ('pending', 'running')
+ORDER BY created_at DESC
+LIMIT 1;
+
+-- name: GetExportJob :one
+SELECT id, user_id, status, created_at, started_at, completed_at, expires_at, error
+FROM export_jobs
+WHERE id = $1
+  AND user_id = $2;
+
+-- name: GetExportArchive :one
+SELECT archive
+FROM export_jobs
+WHERE id = $1
+  AND user_id = $2
+  AND status = 'done'
+  AND expires_at > NOW();
+
+-- name: ClaimExportJob :one
+-- Takes the oldest pending job. Jobs left running by a worker that died are
+-- picked up again after an hour.
+UPDATE export_jobs
+SET status = 'running', started_at = NOW()
+WHERE id = (
+    SELECT id
+    FROM export_jobs
+    WHERE status = 'pending'
+       OR (status = 'running' AND started_at < NOW() - INTERVAL '1 hour')
+    ORDER BY created_at
+    LIMIT 1
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING id, user_id;
+
+-- name: CompleteExportJob :exec
+UPDATE export_jobs
+SET status = 'done', completed_at = NOW(), expires_at = sqlc.arg('expires_at'), archive = sqlc.arg('archive')
+WHERE id = sqlc.arg('id');
+
+-- name: FailExportJob :exec
+UPDATE export_jobs
+SET status = 'failed', completed_at = NOW(), error = $1
+WHERE id = $2;
+
+-- name: DeleteExpiredExportJobs :exec
+DELETE FROM export_jobs
+WHERE expires_at < NOW()
+   OR (status = 'failed' AND completed_at < NOW() - INTERVAL '7 days');
+
+-- name: CountUserChirps :one
+SELECT COUNT(*)
+FROM chirps
+WHERE user_id = $1;
+
+-- name: ExportUserChirps :many
+SELECT *
+FROM chirps
+WHERE user_id = $1
+ORDER BY created_at ASC, id ASC;
+
+-- name: ExportUserChirpRevisions :many
+SELECT r.*
+FROM chirp_revisions r
+JOIN chirps c ON c.id = r.chirp_id
+WHERE c.user_id = $1
+ORDER BY r.chirp_id, r.replaced_at;
+
+-- name: ExportUserLikes :many
+SELECT chirp_id, created_at
+FROM chirp_likes
+WHERE user_id = $1
+ORDER BY created_at ASC;
+
+-- name: ExportUserFollowing :many
+SELECT followee_id, created_at
+FROM follows
+WHERE follower_id = $1
+ORDER BY created_at ASC;
+
+-- name: ExportUserFollowers :many
+SELECT follower_id, created_at
+FROM follows
+WHERE followee_id = $1
+ORDER BY created_at ASC;
+
+-- name: ExportUserSessions :many
+SELECT created_at, last_used_at, user_agent, ip
+FROM sessions
+WHERE user_id = $1
+  AND revoked_at IS NULL
+ORDER BY created_at ASC;
+
+-- name: ExportUserMentions :many
+SELECT chirp_id, created_at, read_at
+FROM chirp_mentions
+WHERE user_id = $1
+ORDER BY created_at ASC;
+
+-- name: ExportUserAuditEvents :many
+SELECT created_at, event, session_id, ip, user_agent
+FROM audit_events
+WHERE user_id = $1
+ORDER BY created_at ASC;
+
+-- name: ExportUserPersonalAccessTokens :many
+SELECT id, name, scopes, created_at, last_used_at, expires_at, revoked_at
+FROM personal_access_tokens
+WHERE user_id = $1
+ORDER BY created_at ASC;
+
+-- name: ExportUserTOTP :one
+SELECT created_at, confirmed_at
+FROM user_totp
+WHERE user_id = $1;
+
+-- name: ExportUserRecoveryCodes :many
+SELECT created_at, used_at
+FROM recovery_codes
+WHERE user_id = $1
+ORDER BY created_at ASC;
@@ -0,0 +1,55 @@
+-- name: FollowUser :exec
+INSERT INTO follows (follower_id, followee_id, created_at)
+VALUES (
+    $1, $2, NOW()
+)
+ON CONFLICT (follower_id, followee_id) DO NOTHING;
+
+-- name: UnfollowUser :exec
+DELETE FROM follows
+WHERE follower_id = $1
+  AND followee_id = $2;
+
+-- name: ListFollowersAsc :many
+SELECT follower_id AS user_id, created_at
+FROM follows
+WHERE followee_id = sqlc.arg('followee_id')
+  AND (created_at, follower_id) > (
+    COALESCE(sqlc.narg('cursor_created_at')::timestamp, '-infinity'::timestamp),
+    COALESCE(sqlc.narg('cursor_id')::uuid, '00000000-0000-0000-0000-000000000000'::uuid)
+)
+ORDER BY created_at ASC, follower_id ASC
+LIMIT sqlc.arg('row_limit');
+
+-- name: ListFollowersDesc :many
+SELECT follower_id AS user_id, created_at
+FROM follows
+WHERE followee_id = sqlc.arg('followee_id')
+  AND (created_at, follower_id) < (
+    COALESCE(sqlc.narg('cursor_created_at')::timestamp, 'infinity'::timestamp),
+    COALESCE(sqlc.narg('cursor_id')::uuid, 'ffffffff-ffff-ffff-ffff-ffffffffffff'::uuid)
+)
+ORDER BY created_at DESC, follower_id DESC
+LIMIT sqlc.arg('row_limit');
+
+-- name: ListFollowingAsc :many
+SELECT followee_id AS user_id, created_at
+FROM follows
+WHERE follower_id = sqlc.arg('follower_id')
+  AND (created_at, followee_id) > (
+    COALESCE(sqlc.narg('cursor_created_at')::timestamp, '-infinity'::timestamp),
+    COALESCE(sqlc.narg('cursor_id')::uuid, '00000000-0000-0000-0000-000000000000'::uuid)
+)
+ORDER BY created_at ASC, followee_id ASC
+LIMIT sqlc.arg('row_limit');
+
+-- name: ListFollowingDesc :many
+SELECT followee_id AS user_id, created_at
+FROM follows
+WHERE follower_id = sqlc.arg('follower_id')
+  AND (created_at, followee_id) < (
+    COALESCE(sqlc.narg('cursor_created_at')::timestamp, 'infinity'::timestamp),
+    COALESCE(sqlc.narg('cursor_id')::uuid, 'ffffffff-ffff-ffff-ffff-ffffffffffff'::uuid)
+)
+ORDER BY created_at DESC, followee_id DESC
+LIMIT sqlc.arg('row_limit');
@@ -0,0 +1,59 @@
+-- name: TagChirp :exec
+-- Creates any hashtags that don't exist yet and links them all to the chirp.
+-- created_at is the chirp's, so tag listings and trending windows follow the
+-- chirp timeline.
+WITH tags AS (
+    INSERT INTO hashtags (id, tag, created_at)
+    SELECT gen_random_uuid(), t, NOW()
+    FROM unnest(sqlc.arg('tags')::text[]) AS t
+    ON CONFLICT (tag) DO UPDATE SET tag = EXCLUDED.tag
+    RETURNING id
+)
+INSERT INTO chirp_hashtags (chirp_id, hashtag_id, created_at)
+SELECT sqlc.arg('chirp_id')::uuid, id, sqlc.arg('created_at')::timestamp
+FROM tags;
+
+-- name: DeleteChirpHashtags :exec
+DELETE FROM chirp_hashtags
+WHERE chirp_id = $1;
+
+-- name: ListHashtagChirpsAsc :many
+SELECT c.*
+FROM chirp_hashtags ch
+JOIN hashtags h ON h.id = ch.hashtag_id
+JOIN chirps c ON c.id = ch.chirp_id
+WHERE h.tag = sqlc.arg('tag')
+  AND c.deleted_at IS NULL
+  AND c.hidden_at IS NULL
+  AND (ch.created_at, ch.chirp_id) > (
+    COALESCE(sqlc.narg('cursor_created_at')::timestamp, '-infinity'::timestamp),
+    COALESCE(sqlc.narg('cursor_id')::uuid, '00000000-0000-0000-0000-000000000000'::uuid)
+)
+ORDER BY ch.created_at ASC, ch.chirp_id ASC
+LIMIT sqlc.arg('row_limit');
+
+-- name: ListHashtagChirpsDesc :many
+SELECT c.*
+FROM chirp_hashtags ch
+JOIN hashtags h ON h.id = ch.hashtag_id
+JOIN chirps c ON c.id = ch.chirp_id
+WHERE h.tag = sqlc.arg('tag')
+  AND c.deleted_at IS NULL
+  AND c.hidden_at IS NULL
+  AND (ch.created_at, ch.chirp_id) < (
+    COALESCE(sqlc.narg('cursor_created_at')::timestamp, 'infinity'::timestamp),
+    COALESCE(sqlc.narg('cursor_id')::uuid, 'ffffffff-ffff-ffff-ffff-ffffffffffff'::uuid)
+)
+ORDER BY ch.created_at DESC, ch.chirp_id DESC
+LIMIT sqlc.arg('row_limit');
+
+-- name: GetTrendingHashtags :many
+SELECT h.tag, COUNT(*) AS chirp_count
+FROM chirp_hashtags ch
+JOIN hashtags h ON h.id = ch.hashtag_id
+JOIN chirps c ON c.id = ch.chirp_id
+WHERE ch.created_at > sqlc.arg('since')::timestamp
+  AND c.hidden_at IS NULL
+GROUP BY h.tag
+ORDER BY chirp_count DESC, h.tag ASC
+LIMIT sqlc.arg('row_limit');
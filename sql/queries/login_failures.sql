@@ -0,0 +1,32 @@
+-- name: GetLoginLockout :one
+SELECT locked_until
+FROM login_failures
+WHERE key = ANY(sqlc.arg('keys')::text[])
+  AND locked_until > NOW()
+ORDER BY locked_until DESC
+LIMIT 1;
+
+-- name: RecordLoginFailure :one
+INSERT INTO login_failures (key, failures, last_failed_at)
+VALUES (sqlc.arg('key'), 1, NOW())
+ON CONFLICT (key) DO UPDATE
+SET failures = CASE
+        WHEN login_failures.last_failed_at < sqlc.arg('window_start')::timestamp THEN 1
+        ELSE login_failures.failures + 1
+    END,
+    last_failed_at = NOW()
+RETURNING failures;
+
+-- name: LockLogin :exec
+UPDATE login_failures
+SET locked_until = sqlc.arg('locked_until')::timestamp
+WHERE key = sqlc.arg('key');
+
+-- name: ClearLoginFailures :exec
+DELETE FROM login_failures
+WHERE key = $1;
+
+-- name: DeleteStaleLoginFailures :execrows
+DELETE FROM login_failures
+WHERE last_failed_at < $1
+  AND (locked_until IS NULL OR locked_until < NOW());
@@ -0,0 +1,29 @@
+-- name: CreatePasswordResetToken :exec
+INSERT INTO password_reset_tokens (token_hash, user_id, created_at, expires_at)
+VALUES ($1, $2, NOW(), $3);
+
+-- name: ConsumePasswordResetToken :one
+-- Marks a live token used and returns its user.
+UPDATE password_reset_tokens
+SET used_at = NOW()
+WHERE token_hash = $1
+  AND used_at IS NULL
+  AND expires_at > NOW()
+RETURNING user_id;
+
+-- name: ExpirePasswordResetTokens :exec
+-- Burns every other outstanding token once a reset succeeds.
+UPDATE password_reset_tokens
+SET used_at = NOW()
+WHERE user_id = $1
+  AND used_at IS NULL;
+
+-- name: HasRecentPasswordResetToken :one
+SELECT EXISTS (
+    SELECT 1
+    FROM password_reset_tokens
+    WHERE user_id = $1
+      AND used_at IS NULL
+      AND expires_at > NOW()
+      AND created_at > sqlc.arg('created_after')::timestamp
+);
@@ -1,19 +1,45 @@
 -- name: CreateRefreshToken :one
-INSERT INTO refresh_tokens (token, created_at, updated_at, user_id, expires_at, revoked_at)
+INSERT INTO refresh_tokens (token_hash, created_at, updated_at, user_id, session_id, expires_at, revoked_at)
 VALUES (
-    $1, NOW(), NOW(), $2, $3, NULL
+    $1, NOW(), NOW(), $2, $3, $4, NULL
 )
 RETURNING *;
 
 -- name: GetUserFromRefreshToken :one
-SELECT token, user_id, expires_at, revoked_at
+SELECT refresh_tokens.token_hash, refresh_tokens.user_id, refresh_tokens.session_id, refresh_tokens.expires_at, refresh_tokens.revoked_at, sessions.scopes
 FROM refresh_tokens
-WHERE token = $1
-  AND revoked_at IS NULL
-  AND expires_at > NOW();
+JOIN sessions ON sessions.id = refresh_tokens.session_id
+WHERE refresh_tokens.token_hash = $1
+  AND refresh_tokens.revoked_at IS NULL
+  AND refresh_tokens.expires_at > NOW()
+  AND sessions.revoked_at IS NULL;
+
+-- name: RotateRefreshToken :execrows
+UPDATE refresh_tokens
+SET revoked_at = NOW(), rotated_at = NOW(), updated_at = NOW()
+WHERE token_hash = $1
+  AND revoked_at IS NULL;
+
+-- name: GetRotatedRefreshToken :one
+SELECT token_hash, user_id, session_id, rotated_at
+FROM refresh_tokens
+WHERE token_hash = $1
+  AND rotated_at IS NOT NULL;
 
 -- name: RevokeRefreshToken :exec
 UPDATE refresh_tokens
 SET revoked_at = NOW(), updated_at = NOW()
-WHERE token = $1
+WHERE token_hash = $1
+  AND revoked_at IS NULL;
+
+-- name: RevokeRefreshTokenSession :exec
+UPDATE sessions
+SET revoked_at = NOW()
+WHERE id = (SELECT session_id FROM refresh_tokens WHERE token_hash = $1)
+  AND revoked_at IS NULL;
+
+-- name: RevokeUserRefreshTokens :exec
+UPDATE refresh_tokens
+SET revoked_at = NOW(), updated_at = NOW()
+WHERE user_id = $1
   AND revoked_at IS NULL;
\ No newline at end of file
@@ -0,0 +1,53 @@
+-- name: CreateSession :one
+INSERT INTO sessions (id, user_id, created_at, last_used_at, user_agent, ip, scopes)
+VALUES (
+    gen_random_uuid(), $1, NOW(), NOW(), $2, $3, $4
+)
+RETURNING *;
+
+-- name: GetActiveSession :one
+SELECT sessions.id
+FROM sessions
+JOIN users ON users.id = sessions.user_id
+WHERE sessions.id = $1
+  AND sessions.user_id = $2
+  AND sessions.revoked_at IS NULL
+  AND users.deleted_at IS NULL;
+
+-- name: TouchSession :exec
+UPDATE sessions
+SET last_used_at = NOW()
+WHERE id = $1;
+
+-- name: ListActiveSessions :many
+SELECT *
+FROM sessions
+WHERE user_id = $1
+  AND revoked_at IS NULL
+  AND EXISTS (
+    SELECT 1
+    FROM refresh_tokens
+    WHERE refresh_tokens.session_id = sessions.id
+      AND refresh_tokens.revoked_at IS NULL
+      AND refresh_tokens.expires_at > NOW()
+  )
+ORDER BY last_used_at DESC;
+
+-- name: RevokeSession :execrows
+UPDATE sessions
+SET revoked_at = NOW()
+WHERE id = $1
+  AND user_id = $2
+  AND revoked_at IS NULL;
+
+-- name: RevokeUserSessions :exec
+UPDATE sessions
+SET revoked_at = NOW()
+WHERE user_id = $1
+  AND revoked_at IS NULL;
+
+-- name: RevokeSessionRefreshTokens :exec
+UPDATE refresh_tokens
+SET revoked_at = NOW(), updated_at = NOW()
+WHERE session_id = $1
+  AND revoked_at IS NULL;
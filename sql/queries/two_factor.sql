@@ -0,0 +1,47 @@
+-- name: StartTOTPEnrollment :exec
+-- Replaces any unconfirmed enrollment; confirmed ones have to be disabled
+-- first.
+INSERT INTO user_totp (user_id, secret, created_at)
+VALUES ($1, $2, NOW())
+ON CONFLICT (user_id) DO UPDATE
+SET secret = EXCLUDED.secret, created_at = EXCLUDED.created_at, last_used_step = 0
+WHERE user_totp.confirmed_at IS NULL;
+
+-- name: GetUserTOTP :one
+SELECT *
+FROM user_totp
+WHERE user_id = $1;
+
+-- name: ConfirmTOTP :exec
+UPDATE user_totp
+SET confirmed_at = NOW(), last_used_step = sqlc.arg('step')
+WHERE user_id = sqlc.arg('user_id')
+  AND confirmed_at IS NULL;
+
+-- name: UseTOTPStep :execrows
+-- Records the step of an accepted code. Zero rows means the code (or an
+-- older one) was already used, so it must be rejected.
+UPDATE user_totp
+SET last_used_step = sqlc.arg('step')
+WHERE user_id = sqlc.arg('user_id')
+  AND confirmed_at IS NOT NULL
+  AND last_used_step < sqlc.arg('step');
+
+-- name: DeleteUserTOTP :exec
+DELETE FROM user_totp
+WHERE user_id = $1;
+
+-- name: CreateRecoveryCodes :exec
+INSERT INTO recovery_codes (user_id, code_hash, created_at)
+SELECT sqlc.arg('user_id')::uuid, unnest(sqlc.arg('code_hashes')::text[]), NOW();
+
+-- name: UseRecoveryCode :execrows
+UPDATE recovery_codes
+SET used_at = NOW()
+WHERE user_id = $1
+  AND code_hash = $2
+  AND used_at IS NULL;
+
+-- name: DeleteRecoveryCodes :exec
+DELETE FROM recovery_codes
+WHERE user_id = $1;
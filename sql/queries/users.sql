@@ -1,31 +1,128 @@
 -- name: CreateUser :one
-INSERT INTO users(id, created_at, updated_at, email, hashed_password)
+INSERT INTO users(id, created_at, updated_at, email, hashed_password, username, display_name, bio, location)
 VALUES (
-    gen_random_uuid(), NOW(), NOW(), $1, $2
+    gen_random_uuid(), NOW(), NOW(), $1, $2, $3, $4, $5, $6
 )
 RETURNING *;
 
 -- name: GetUser :one
-SELECT id, email, created_at, updated_at, is_chirpy_red
+SELECT id, email, created_at, updated_at, is_chirpy_red, email_verified
 FROM users
 WHERE id = $1;
 
 -- name: ResetUsers :exec
-DELETE FROM users;
+-- Keeps the deleted-user placeholder, which only its migration creates.
+DELETE FROM users
+WHERE id <> sqlc.arg('keep_id');
 
 -- name: GetUserByEmail :one
-SELECT id, email, created_at, updated_at, hashed_password, is_chirpy_red
+SELECT *
 FROM users
 WHERE email = $1;
 
 -- name: UpdateUser :one
+-- Profile fields left NULL keep their current value. A new email address
+-- has to be verified again.
 UPDATE users
-SET email = $1, hashed_password = $2, updated_at = NOW()
-WHERE id = $3
+SET email = sqlc.arg('email'),
+    hashed_password = sqlc.arg('hashed_password'),
+    username = COALESCE(sqlc.narg('username')::text, username),
+    display_name = COALESCE(sqlc.narg('display_name')::text, display_name),
+    bio = COALESCE(sqlc.narg('bio')::text, bio),
+    location = COALESCE(sqlc.narg('location')::text, location),
+    email_verified = email_verified AND email = sqlc.arg('email'),
+    updated_at = NOW()
+WHERE id = sqlc.arg('id')
 RETURNING *;
 
 -- name: UpgradeUserToRed :one
 UPDATE users
 SET is_chirpy_red = true, updated_at = Now()
 WHERE id = $1
-RETURNING *;
\ No newline at end of file
+RETURNING *;
+
+-- name: GetUsersByEmailLocalPart :many
+-- Resolves @mentions. A local part shared by several addresses comes back
+-- once per user; callers should treat that handle as ambiguous.
+SELECT id, lower(split_part(email, '@', 1))::text AS handle
+FROM users
+WHERE lower(split_part(email, '@', 1)) = ANY(sqlc.arg('handles')::text[])
+  AND deleted_at IS NULL;
+
+-- name: GetUsersByUsername :many
+SELECT id, username::text AS handle
+FROM users
+WHERE username = ANY(sqlc.arg('usernames')::text[])
+  AND deleted_at IS NULL;
+
+-- name: GetUserProfileByUsername :one
+SELECT u.id, u.created_at, u.username, u.display_name, u.bio, u.location, u.is_chirpy_red,
+    (SELECT COUNT(*) FROM follows f WHERE f.followee_id = u.id)::bigint AS follower_count,
+    (SELECT COUNT(*) FROM follows f WHERE f.follower_id = u.id)::bigint AS following_count,
+    (SELECT COUNT(*) FROM chirps c WHERE c.user_id = u.id AND c.deleted_at IS NULL)::bigint AS chirp_count
+FROM users u
+WHERE u.username = $1
+  AND u.deleted_at IS NULL;
+
+-- name: GetUserByIDForUpdate :one
+SELECT *
+FROM users
+WHERE id = $1
+FOR UPDATE;
+
+-- name: PatchUser :one
+-- Writes back every field; callers lock the row with GetUserByIDForUpdate
+-- and only change what the patch touched.
+UPDATE users
+SET email = sqlc.arg('email'),
+    hashed_password = sqlc.arg('hashed_password'),
+    username = sqlc.narg('username'),
+    display_name = sqlc.arg('display_name'),
+    bio = sqlc.arg('bio'),
+    location = sqlc.arg('location'),
+    email_verified = sqlc.arg('email_verified'),
+    updated_at = NOW()
+WHERE id = sqlc.arg('id')
+RETURNING *;
+
+-- name: MarkEmailVerified :one
+-- Only verifies the address the token was sent to; a token issued before an
+-- email change matches no row.
+UPDATE users
+SET email_verified = true, updated_at = NOW()
+WHERE id = $1 AND email = $2
+RETURNING *;
+
+-- name: UpdateUserPassword :exec
+UPDATE users
+SET hashed_password = $1, updated_at = NOW()
+WHERE id = $2;
+
+-- name: GetUserByID :one
+SELECT *
+FROM users
+WHERE id = $1;
+
+-- name: SoftDeleteUser :execrows
+UPDATE users
+SET deleted_at = NOW(), updated_at = NOW()
+WHERE id = $1
+  AND deleted_at IS NULL;
+
+-- name: RestoreUser :exec
+UPDATE users
+SET deleted_at = NULL, updated_at = NOW()
+WHERE id = $1;
+
+-- name: ListPurgeableUsers :many
+-- Accounts whose deletion grace period is over.
+SELECT id
+FROM users
+WHERE deleted_at < sqlc.arg('deleted_before')::timestamp;
+
+-- name: PurgeUser :execrows
+-- Hard-deletes an account. Its chirps, tokens and the rest go with it
+-- through ON DELETE CASCADE.
+DELETE FROM users
+WHERE id = $1
+  AND deleted_at IS NOT NULL;
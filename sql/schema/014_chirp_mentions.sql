@@ -0,0 +1,15 @@
+-- +goose Up
+CREATE TABLE chirp_mentions (
+    chirp_id UUID NOT NULL REFERENCES chirps(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL,
+    read_at TIMESTAMP NULL,
+    PRIMARY KEY (chirp_id, user_id)
+);
+
+CREATE INDEX chirp_mentions_user_id_created_at_idx ON chirp_mentions (user_id, created_at, chirp_id);
+CREATE INDEX users_email_local_part_idx ON users (lower(split_part(email, '@', 1)));
+
+-- +goose Down
+DROP INDEX users_email_local_part_idx;
+DROP TABLE chirp_mentions;
@@ -0,0 +1,13 @@
+-- +goose Up
+ALTER TABLE users
+ADD username TEXT NULL UNIQUE,
+ADD display_name TEXT NOT NULL DEFAULT '',
+ADD bio TEXT NOT NULL DEFAULT '',
+ADD location TEXT NOT NULL DEFAULT '';
+
+-- +goose Down
+ALTER TABLE users
+DROP username,
+DROP display_name,
+DROP bio,
+DROP location;
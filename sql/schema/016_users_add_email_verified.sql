@@ -0,0 +1,7 @@
+-- +goose Up
+ALTER TABLE users
+ADD email_verified BOOLEAN NOT NULL DEFAULT false;
+
+-- +goose Down
+ALTER TABLE users
+DROP email_verified;
@@ -0,0 +1,17 @@
+-- +goose Up
+ALTER TABLE users
+ADD deleted_at TIMESTAMP NULL;
+
+ALTER TABLE chirps
+ADD hidden_at TIMESTAMP NULL;
+
+CREATE INDEX users_deleted_at_idx ON users (deleted_at) WHERE deleted_at IS NOT NULL;
+
+-- +goose Down
+DROP INDEX users_deleted_at_idx;
+
+ALTER TABLE chirps
+DROP hidden_at;
+
+ALTER TABLE users
+DROP deleted_at;
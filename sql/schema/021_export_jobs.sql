@@ -0,0 +1,18 @@
+-- +goose Up
+CREATE TABLE export_jobs (
+    id UUID PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'running', 'done', 'failed')),
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    started_at TIMESTAMP NULL,
+    completed_at TIMESTAMP NULL,
+    expires_at TIMESTAMP NULL,
+    error TEXT NULL,
+    archive BYTEA NULL
+);
+
+CREATE INDEX export_jobs_user_id_created_at_idx ON export_jobs (user_id, created_at);
+CREATE INDEX export_jobs_pending_idx ON export_jobs (created_at) WHERE status IN ('pending', 'running');
+
+-- +goose Down
+DROP TABLE export_jobs;
@@ -0,0 +1,35 @@
+-- +goose Up
+CREATE TABLE sessions (
+    id UUID PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    last_used_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    user_agent TEXT NOT NULL DEFAULT '',
+    ip TEXT NOT NULL DEFAULT '',
+    revoked_at TIMESTAMP NULL
+);
+
+CREATE INDEX sessions_user_id_idx ON sessions (user_id) WHERE revoked_at IS NULL;
+
+-- Existing refresh tokens each become their own session.
+ALTER TABLE refresh_tokens
+ADD session_id UUID NULL;
+
+UPDATE refresh_tokens SET session_id = gen_random_uuid();
+
+INSERT INTO sessions (id, user_id, created_at, last_used_at, revoked_at)
+SELECT session_id, user_id, created_at, updated_at, revoked_at
+FROM refresh_tokens;
+
+ALTER TABLE refresh_tokens
+ALTER session_id SET NOT NULL,
+ADD CONSTRAINT refresh_tokens_session_id_fkey
+    FOREIGN KEY (session_id) REFERENCES sessions(id) ON DELETE CASCADE;
+
+CREATE INDEX refresh_tokens_session_id_idx ON refresh_tokens (session_id);
+
+-- +goose Down
+ALTER TABLE refresh_tokens
+DROP session_id;
+
+DROP TABLE sessions;
@@ -0,0 +1,21 @@
+-- +goose Up
+ALTER TABLE refresh_tokens
+ADD rotated_at TIMESTAMP NULL;
+
+CREATE TABLE audit_events (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    event TEXT NOT NULL,
+    user_id UUID NULL REFERENCES users(id) ON DELETE CASCADE,
+    session_id UUID NULL,
+    ip TEXT NOT NULL DEFAULT '',
+    user_agent TEXT NOT NULL DEFAULT ''
+);
+
+CREATE INDEX audit_events_user_id_idx ON audit_events (user_id, created_at);
+
+-- +goose Down
+DROP TABLE audit_events;
+
+ALTER TABLE refresh_tokens
+DROP rotated_at;
@@ -0,0 +1,15 @@
+-- +goose Up
+-- Rehash in place so existing sessions keep working.
+UPDATE refresh_tokens
+SET token = encode(sha256(convert_to(token, 'UTF8')), 'hex');
+
+ALTER TABLE refresh_tokens
+RENAME COLUMN token TO token_hash;
+
+-- +goose Down
+-- The hashes can't be turned back into tokens, so everyone has to log in
+-- again.
+DELETE FROM refresh_tokens;
+
+ALTER TABLE refresh_tokens
+RENAME COLUMN token_hash TO token;
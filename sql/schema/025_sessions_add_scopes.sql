@@ -0,0 +1,11 @@
+-- +goose Up
+ALTER TABLE sessions
+ADD scopes TEXT[] NOT NULL DEFAULT '{}';
+
+-- Sessions from before scopes existed keep everything a login grants.
+UPDATE sessions
+SET scopes = ARRAY['chirps:write', 'chirps:delete', 'profile:write', 'account'];
+
+-- +goose Down
+ALTER TABLE sessions
+DROP scopes;
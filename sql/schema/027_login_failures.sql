@@ -0,0 +1,12 @@
+-- +goose Up
+CREATE TABLE login_failures (
+    key TEXT PRIMARY KEY,
+    failures INTEGER NOT NULL,
+    last_failed_at TIMESTAMP NOT NULL,
+    locked_until TIMESTAMP NULL
+);
+
+CREATE INDEX login_failures_last_failed_at_idx ON login_failures (last_failed_at);
+
+-- +goose Down
+DROP TABLE login_failures;
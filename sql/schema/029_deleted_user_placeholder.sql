@@ -0,0 +1,19 @@
+-- +goose Up
+-- The account that keeps purged users' chirps which others replied to or
+-- quoted. With no password hash, nobody can log in as it. Addresses under
+-- the reserved .invalid domain can't be delivered to, so no real user
+-- needs one, and refusing them keeps the placeholder's address free.
+ALTER TABLE users
+ADD CONSTRAINT users_email_not_reserved
+CHECK (id = '00000000-0000-0000-0000-00000000de1e' OR lower(email) NOT LIKE '%.invalid');
+
+INSERT INTO users (id, created_at, updated_at, email, hashed_password, display_name)
+VALUES ('00000000-0000-0000-0000-00000000de1e', NOW(), NOW(), 'deleted-user@chirpy.invalid', '', 'Deleted user')
+ON CONFLICT (id) DO NOTHING;
+
+-- +goose Down
+DELETE FROM users
+WHERE id = '00000000-0000-0000-0000-00000000de1e';
+
+ALTER TABLE users
+DROP CONSTRAINT users_email_not_reserved;